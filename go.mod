@@ -5,5 +5,6 @@ go 1.14
 require (
 	github.com/aws/aws-sdk-go v1.29.29
 	github.com/davecgh/go-spew v1.1.1 // indirect
+	golang.org/x/time v0.0.0-20200416051211-89c76fbcd5d1
 	gopkg.in/yaml.v2 v2.2.4 // indirect
 )
@@ -2,8 +2,9 @@ package main
 
 import (
 	"fmt"
+	"log"
+	"os"
 	"s3test/s3copier"
-	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
@@ -14,19 +15,31 @@ var copier *s3copier.S3Copier
 var s3client *s3.S3
 
 func init() {
-	session := session.New(&aws.Config{
+	config := &aws.Config{
 		Region: aws.String("ap-northeast-1"),
-	})
+	}
+	// MinIO などの S3 互換ストレージに向ける場合
+	if endpoint := os.Getenv("S3_ENDPOINT"); endpoint != "" {
+		config.Endpoint = aws.String(endpoint)
+		config.S3ForcePathStyle = aws.Bool(true)
+	}
+	session := session.New(config)
 	s3client = s3.New(session)
-	copier = s3copier.NewS3Copier(session)
+	copier = s3copier.NewS3Copier(session, s3copier.WithLogger(stdLogger{}))
 }
 
+type stdLogger struct{}
+
+func (stdLogger) Debugf(format string, args ...interface{}) {}
+func (stdLogger) Infof(format string, args ...interface{})  { log.Printf(format, args...) }
+func (stdLogger) Errorf(format string, args ...interface{}) { log.Printf("ERROR: "+format, args...) }
+
 func main() {
-	s := time.Now()
-	err := copier.CopyWithPrefix("test-from-bucket", "test-to-bucket", "prefix/001")
+	result, err := copier.CopyWithPrefixResult("test-from-bucket", "test-to-bucket", "prefix/001")
 	if err != nil {
 		panic(err)
 	}
-	duration := time.Now().Sub(s)
-	fmt.Printf("duration: %v\n", duration)
+	fmt.Printf("objects: %d (single part: %d, multipart: %d)\n", result.ObjectsCopied, result.SinglePartCount, result.MultiPartCount)
+	fmt.Printf("bytes: %d\n", result.BytesCopied)
+	fmt.Printf("duration: %v\n", result.Duration)
 }
@@ -0,0 +1,92 @@
+package s3copier
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// WithACL sets a canned ACL, e.g. s3.ObjectCannedACLBucketOwnerFullControl, on the destination objects.
+// S3 rejects requests with both a canned ACL and grants, so it can't be combined with
+// the WithGrant* options or WithPreserveACL.
+func WithACL(acl string) Option {
+	return WithRequestDecorator(func(op string, input interface{}) {
+		switch in := input.(type) {
+		case *s3.CopyObjectInput:
+			in.ACL = aws.String(acl)
+		case *s3.CreateMultipartUploadInput:
+			in.ACL = aws.String(acl)
+		}
+	})
+}
+
+// WithPreserveACL reads the ACL of each source object with GetObjectAcl and sets the same grants
+// on the destination. The owner of the destination objects is still the copying account.
+func WithPreserveACL() Option {
+	return func(c *S3Copier) {
+		c.preserveACL = true
+	}
+}
+
+// sourceGrants reads the ACL of obj with client and returns the grants
+// as the x-amz-grant-* header values, indexed by grantPermission.
+func (c *S3Copier) sourceGrants(ctx context.Context, client S3Client, obj *S3Object) ([4]*string, error) {
+	var grants [4]*string
+	input := &s3.GetObjectAclInput{
+		Bucket:    aws.String(obj.bucket),
+		Key:       aws.String(obj.key),
+		VersionId: obj.versionIdValue(),
+	}
+	c.decorate(OP_GET_OBJECT_ACL, input)
+	var output *s3.GetObjectAclOutput
+	err := c.retryer.do(ctx, func() error {
+		var err error
+		output, err = client.GetObjectAclWithContext(ctx, input)
+		return err
+	})
+	if err != nil {
+		return grants, err
+	}
+
+	var grantees [4][]string
+	for _, g := range output.Grants {
+		grantee := granteeValue(g.Grantee)
+		if grantee == "" {
+			continue
+		}
+		switch aws.StringValue(g.Permission) {
+		case s3.PermissionRead:
+			grantees[grantRead] = append(grantees[grantRead], grantee)
+		case s3.PermissionReadAcp:
+			grantees[grantReadACP] = append(grantees[grantReadACP], grantee)
+		case s3.PermissionWriteAcp:
+			grantees[grantWriteACP] = append(grantees[grantWriteACP], grantee)
+		case s3.PermissionFullControl:
+			grantees[grantFullControl] = append(grantees[grantFullControl], grantee)
+		}
+	}
+	for i, values := range grantees {
+		if len(values) > 0 {
+			grants[i] = aws.String(strings.Join(values, ", "))
+		}
+	}
+	return grants, nil
+}
+
+func granteeValue(g *s3.Grantee) string {
+	if g == nil {
+		return ""
+	}
+	switch aws.StringValue(g.Type) {
+	case s3.TypeCanonicalUser:
+		return fmt.Sprintf("id=\"%s\"", aws.StringValue(g.ID))
+	case s3.TypeGroup:
+		return groupGrantee(aws.StringValue(g.URI))
+	case s3.TypeAmazonCustomerByEmail:
+		return fmt.Sprintf("emailAddress=\"%s\"", aws.StringValue(g.EmailAddress))
+	}
+	return ""
+}
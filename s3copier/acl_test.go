@@ -0,0 +1,54 @@
+package s3copier
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func TestWithACL(t *testing.T) {
+	mock := newMockS3()
+	mock.put("src", "p/single", 1)
+	mock.put("src", "p/multi", 20*ONE_MB)
+	c := NewS3CopierWithClient(mock, WithACL(s3.ObjectCannedACLPublicRead))
+
+	if err := c.CopyWithPrefix("src", "dest", "p/"); err != nil {
+		t.Fatalf("CopyWithPrefix failed: %v", err)
+	}
+	if acl := aws.StringValue(mock.inputs(OP_COPY_OBJECT)[0].(*s3.CopyObjectInput).ACL); acl != s3.ObjectCannedACLPublicRead {
+		t.Errorf("CopyObject ACL = %q, want %q", acl, s3.ObjectCannedACLPublicRead)
+	}
+	if acl := aws.StringValue(mock.inputs(OP_CREATE_MULTIPART_UPLOAD)[0].(*s3.CreateMultipartUploadInput).ACL); acl != s3.ObjectCannedACLPublicRead {
+		t.Errorf("CreateMultipartUpload ACL = %q, want %q", acl, s3.ObjectCannedACLPublicRead)
+	}
+}
+
+func TestWithPreserveACL(t *testing.T) {
+	grants := []*s3.Grant{
+		{Grantee: &s3.Grantee{Type: aws.String(s3.TypeCanonicalUser), ID: aws.String("owner")}, Permission: aws.String(s3.PermissionFullControl)},
+		{Grantee: &s3.Grantee{Type: aws.String(s3.TypeGroup), URI: aws.String(GROUP_ALL_USERS)}, Permission: aws.String(s3.PermissionRead)},
+		{Grantee: &s3.Grantee{Type: aws.String(s3.TypeAmazonCustomerByEmail), EmailAddress: aws.String("a@example.com")}, Permission: aws.String(s3.PermissionRead)},
+	}
+	mock := newMockS3()
+	mock.put("src", "p/single", 1).grants = grants
+	mock.put("src", "p/multi", 20*ONE_MB).grants = grants
+	c := NewS3CopierWithClient(mock, WithPreserveACL())
+
+	if err := c.CopyWithPrefix("src", "dest", "p/"); err != nil {
+		t.Fatalf("CopyWithPrefix failed: %v", err)
+	}
+	wantRead := `uri="http://acs.amazonaws.com/groups/global/AllUsers", emailAddress="a@example.com"`
+	wantFullControl := `id="owner"`
+	single := mock.inputs(OP_COPY_OBJECT)[0].(*s3.CopyObjectInput)
+	if aws.StringValue(single.GrantRead) != wantRead || aws.StringValue(single.GrantFullControl) != wantFullControl {
+		t.Errorf("CopyObject grants read %q, full control %q; want %q, %q", aws.StringValue(single.GrantRead), aws.StringValue(single.GrantFullControl), wantRead, wantFullControl)
+	}
+	multi := mock.inputs(OP_CREATE_MULTIPART_UPLOAD)[0].(*s3.CreateMultipartUploadInput)
+	if aws.StringValue(multi.GrantRead) != wantRead || aws.StringValue(multi.GrantFullControl) != wantFullControl {
+		t.Errorf("CreateMultipartUpload grants read %q, full control %q; want %q, %q", aws.StringValue(multi.GrantRead), aws.StringValue(multi.GrantFullControl), wantRead, wantFullControl)
+	}
+	if single.GrantReadACP != nil || single.GrantWriteACP != nil {
+		t.Errorf("CopyObject has GrantReadACP %v and GrantWriteACP %v, want nil", single.GrantReadACP, single.GrantWriteACP)
+	}
+}
@@ -0,0 +1,123 @@
+package s3copier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errAbortTimedOut = errors.New("abort timed out")
+
+// activeUploads tracks the multipart uploads in progress by upload id.
+type activeUploads struct {
+	mu      sync.Mutex
+	uploads map[string]*S3Object
+}
+
+func (u *activeUploads) add(uploadId string, dest *S3Object) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.uploads == nil {
+		u.uploads = map[string]*S3Object{}
+	}
+	u.uploads[uploadId] = dest
+}
+
+func (u *activeUploads) remove(uploadId string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	delete(u.uploads, uploadId)
+}
+
+func (u *activeUploads) snapshot() map[string]*S3Object {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	uploads := make(map[string]*S3Object, len(u.uploads))
+	for id, dest := range u.uploads {
+		uploads[id] = dest
+	}
+	return uploads
+}
+
+// WithDrainTimeout bounds how long Close waits for the multipart uploads to be aborted (30 seconds
+// by default), so that shutting down doesn't hang on an unresponsive S3, e.g. within a Lambda timeout.
+// Each abort of a failed or cancelled copy also gives up after d. It panics when d is not positive.
+func WithDrainTimeout(d time.Duration) Option {
+	if d <= 0 {
+		panic(fmt.Sprintf("s3copier: drain timeout must be positive, got %v", d))
+	}
+	return func(c *S3Copier) {
+		c.drainTimeout = d
+	}
+}
+
+// Close aborts the multipart uploads the copier has started but not completed nor aborted,
+// e.g. when the process shuts down in the middle of a copy. Copies still running fail, so cancel
+// them first. The copier can be used after Close.
+// The uploads are aborted concurrently and Close returns after the drain timeout (see WithDrainTimeout)
+// even if some aborts haven't finished. The returned error names the uploads which timed out.
+func (c *S3Copier) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.drainTimeout)
+	defer cancel()
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		failed   int
+		timedOut []string
+	)
+	uploads := c.uploads.snapshot()
+	// 終わらなかった abort もタイムアウトとして報告する
+	pending := make(map[string]*S3Object, len(uploads))
+	for uploadId, dest := range uploads {
+		pending[uploadId] = dest
+	}
+	for uploadId, dest := range uploads {
+		wg.Add(1)
+		go func(uploadId string, dest *S3Object) {
+			defer wg.Done()
+			err := c.abortMultipartUploadContext(ctx, dest, &uploadId)
+			mu.Lock()
+			defer mu.Unlock()
+			delete(pending, uploadId)
+			if err == nil {
+				return
+			}
+			failed++
+			if err == errAbortTimedOut {
+				timedOut = append(timedOut, fmt.Sprintf("%s (%s)", dest.bucketKeyPath(), uploadId))
+			}
+		}(uploadId, dest)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	// context を無視する client でも Close は返る
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(pending) > 0 {
+		c.logger.Errorf("%d multipart uploads are still being aborted after %v", len(pending), c.drainTimeout)
+	}
+	failed += len(pending)
+	for uploadId, dest := range pending {
+		timedOut = append(timedOut, fmt.Sprintf("%s (%s)", dest.bucketKeyPath(), uploadId))
+	}
+	if len(timedOut) > 0 {
+		sort.Strings(timedOut)
+		return fmt.Errorf("failed to abort %d multipart uploads, timed out: %s", failed, strings.Join(timedOut, ", "))
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to abort %d multipart uploads", failed)
+	}
+	return nil
+}
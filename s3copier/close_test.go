@@ -0,0 +1,120 @@
+package s3copier
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// startStalledCopy starts a multipart copy of src/key whose parts hang until the returned cancel is called,
+// and returns once the upload is tracked. The returned channel receives the error of the copy.
+func startStalledCopy(t *testing.T, c *S3Copier) (string, context.CancelFunc, <-chan error) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- c.CopyToContext(ctx, NewS3Object("src", "key"), NewS3Object("dest", "key"))
+	}()
+	for i := 0; i < 100; i++ {
+		for uploadId := range c.uploads.snapshot() {
+			return uploadId, cancel, done
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	t.Fatal("multipart upload is not started")
+	return "", nil, nil
+}
+
+func stallParts(ctx aws.Context, op string, input interface{}) error {
+	if op == OP_UPLOAD_PART_COPY {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return nil
+}
+
+func TestCloseAbortsUploadsInProgress(t *testing.T) {
+	mock := newMockS3()
+	mock.put("src", "key", 20*ONE_MB)
+	mock.hook = stallParts
+	c := NewS3CopierWithClient(mock, WithMaxRetries(0))
+	uploadId, cancel, done := startStalledCopy(t, c)
+
+	if err := c.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	aborts := mock.inputs(OP_ABORT_MULTIPART_UPLOAD)
+	if len(aborts) != 1 || *aborts[0].(*s3.AbortMultipartUploadInput).UploadId != uploadId {
+		t.Errorf("%d uploads are aborted, want %s", len(aborts), uploadId)
+	}
+	if n := len(c.uploads.snapshot()); n != 0 {
+		t.Errorf("%d uploads are still tracked after Close", n)
+	}
+	cancel()
+	if err := <-done; err == nil {
+		t.Error("CopyTo of the closed upload succeeded")
+	}
+}
+
+func TestCloseReportsAbortTimeouts(t *testing.T) {
+	mock := newMockS3()
+	mock.put("src", "key", 20*ONE_MB)
+	mock.hook = func(ctx aws.Context, op string, input interface{}) error {
+		if op == OP_ABORT_MULTIPART_UPLOAD {
+			// 応答しない S3
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return stallParts(ctx, op, input)
+	}
+	c := NewS3CopierWithClient(mock, WithMaxRetries(0), WithDrainTimeout(50*time.Millisecond))
+	uploadId, cancel, done := startStalledCopy(t, c)
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	start := time.Now()
+	err := c.Close()
+	if err == nil || !strings.Contains(err.Error(), "timed out") || !strings.Contains(err.Error(), "dest/key ("+uploadId+")") {
+		t.Errorf("Close returned %v, want dest/key (%s) to be reported as timed out", err, uploadId)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Close took %v, want it to give up after the abort timeout", elapsed)
+	}
+}
+
+func TestCloseReturnsAfterDrainTimeout(t *testing.T) {
+	mock := newMockS3()
+	mock.put("src", "key", 20*ONE_MB)
+	release := make(chan struct{})
+	mock.hook = func(ctx aws.Context, op string, input interface{}) error {
+		if op == OP_ABORT_MULTIPART_UPLOAD {
+			// context を見ずに止まったままの client
+			<-release
+			return nil
+		}
+		return stallParts(ctx, op, input)
+	}
+	c := NewS3CopierWithClient(mock, WithMaxRetries(0), WithDrainTimeout(50*time.Millisecond))
+	uploadId, cancel, done := startStalledCopy(t, c)
+	defer func() {
+		close(release)
+		cancel()
+		<-done
+	}()
+
+	start := time.Now()
+	err := c.Close()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Close took %v, want it to return after the drain timeout", elapsed)
+	}
+	if err == nil || !strings.Contains(err.Error(), "failed to abort 1 multipart uploads, timed out: dest/key ("+uploadId+")") {
+		t.Errorf("Close returned %v, want dest/key (%s) to be reported as timed out", err, uploadId)
+	}
+}
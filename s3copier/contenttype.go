@@ -0,0 +1,31 @@
+package s3copier
+
+import (
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// WithContentTypeByExtension sets the ContentType of the destination objects by the extension
+// of the source key, e.g. {".m3u8": "application/vnd.apple.mpegurl"}. Extensions are matched
+// case-insensitively and include the leading dot. Objects with other extensions keep the ContentType
+// of the source. The mapping is added to the default one, which only has ".m3u8".
+// The source objects are never modified.
+func WithContentTypeByExtension(contentTypes map[string]string) Option {
+	return func(c *S3Copier) {
+		for ext, contentType := range contentTypes {
+			c.contentTypes[strings.ToLower(ext)] = contentType
+		}
+	}
+}
+
+// contentTypeFor returns the ContentType for the destination of key.
+func (c *S3Copier) contentTypeFor(key string, srcHead *s3.HeadObjectOutput) *string {
+	contentType, ok := c.contentTypes[strings.ToLower(path.Ext(key))]
+	if !ok {
+		return srcHead.ContentType
+	}
+	return aws.String(contentType)
+}
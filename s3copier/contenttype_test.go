@@ -0,0 +1,33 @@
+package s3copier
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestM3u8ContentTypeIsSetOnDestinationOnly(t *testing.T) {
+	mock := newMockS3()
+	mock.put("src", "p/index.m3u8", 1).contentType = "application/octet-stream"
+	mock.put("src", "p/big.M3U8", 20*ONE_MB).contentType = "application/octet-stream"
+	c := NewS3CopierWithClient(mock)
+
+	if err := c.CopyWithPrefix("src", "dest", "p/"); err != nil {
+		t.Fatalf("CopyWithPrefix failed: %v", err)
+	}
+	writes := []string{OP_COPY_OBJECT, OP_CREATE_MULTIPART_UPLOAD, OP_UPLOAD_PART_COPY, OP_COMPLETE_MULTIPART_UPLOAD, OP_PUT_OBJECT, OP_DELETE_OBJECT, OP_DELETE_OBJECTS}
+	for _, op := range writes {
+		for _, in := range mock.inputs(op) {
+			if bucket := reflect.ValueOf(in).Elem().FieldByName("Bucket").Elem().String(); bucket != "dest" {
+				t.Errorf("%s is sent to bucket %s, want only dest", op, bucket)
+			}
+		}
+	}
+	for _, key := range []string{"p/index.m3u8", "p/big.M3U8"} {
+		if got := mock.object("dest", key).contentType; got != CONTENT_TYPE_M3U8 {
+			t.Errorf("dest/%s has ContentType %s, want %s", key, got, CONTENT_TYPE_M3U8)
+		}
+		if got := mock.object("src", key).contentType; got != "application/octet-stream" {
+			t.Errorf("src/%s has ContentType %s, want it unchanged", key, got)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package s3copier
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// CopyError is an object which failed to copy.
+type CopyError struct {
+	Key string
+	Err error
+}
+
+// CopyErrors is returned by CopyWithPrefix under WithContinueOnError when some objects failed.
+type CopyErrors []CopyError
+
+func (e CopyErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, ce := range e {
+		messages = append(messages, fmt.Sprintf("%s: %v", ce.Key, ce.Err))
+	}
+	return fmt.Sprintf("%d objects failed to copy: %s", len(e), strings.Join(messages, "; "))
+}
+
+// Is reports whether any of the objects failed with target, e.g. errors.Is(err, ErrAccessDenied).
+func (e CopyErrors) Is(target error) bool {
+	for _, ce := range e {
+		if errors.Is(ce.Err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithContinueOnError makes CopyWithPrefix go on copying the other objects when an object fails,
+// instead of stopping the whole run. The failures are returned as CopyErrors at the end,
+// and also in CopyResult.Errors. Failing to list the source still stops the run.
+func WithContinueOnError(continueOnError bool) Option {
+	return func(c *S3Copier) {
+		c.continueOnError = continueOnError
+	}
+}
@@ -0,0 +1,84 @@
+package s3copier
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func TestContinueOnErrorCollectsFailures(t *testing.T) {
+	mock := newMockS3()
+	keys := mock.putKeys("src", "p/", 20, 1)
+	failing := map[string]bool{"p/3": true, "p/7": true, "p/15": true}
+	mock.hook = func(ctx aws.Context, op string, input interface{}) error {
+		if in, ok := input.(*s3.CopyObjectInput); ok && failing[*in.Key] {
+			return awsError("AccessDenied", 403)
+		}
+		return nil
+	}
+	c := NewS3CopierWithClient(mock, WithContinueOnError(true), WithWorkerCount(4))
+
+	result, err := c.CopyWithPrefixResult("src", "dest", "p/")
+	var errs CopyErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("CopyWithPrefixResult returned %v, want CopyErrors", err)
+	}
+	var failed []string
+	for _, ce := range errs {
+		failed = append(failed, ce.Key)
+		if !errors.Is(ce.Err, ErrAccessDenied) {
+			t.Errorf("%s failed with %v, want ErrAccessDenied", ce.Key, ce.Err)
+		}
+	}
+	sort.Strings(failed)
+	if want := []string{"p/15", "p/3", "p/7"}; !reflect.DeepEqual(failed, want) {
+		t.Errorf("failed keys are %v, want %v", failed, want)
+	}
+	if len(result.Errors) != 3 || result.ObjectsCopied != 17 {
+		t.Errorf("%d objects copied and %d errors in the result, want 17 and 3", result.ObjectsCopied, len(result.Errors))
+	}
+	for _, k := range keys {
+		if copied := mock.object("dest", k) != nil; copied == failing[k] {
+			t.Errorf("dest/%s copied = %v", k, copied)
+		}
+	}
+}
+
+func TestContinueOnErrorSucceedsWithoutFailures(t *testing.T) {
+	mock := newMockS3()
+	mock.putKeys("src", "p/", 5, 1)
+	c := NewS3CopierWithClient(mock, WithContinueOnError(true))
+
+	result, err := c.CopyWithPrefixResult("src", "dest", "p/")
+	if err != nil || result.ObjectsCopied != 5 {
+		t.Errorf("CopyWithPrefixResult copied %d objects with %v, want 5 without error", result.ObjectsCopied, err)
+	}
+}
+
+func TestCopyErrorsMatchTheObjectErrors(t *testing.T) {
+	mock := newMockS3()
+	mock.putKeys("src", "p/", 3, 1)
+	mock.hook = func(ctx aws.Context, op string, input interface{}) error {
+		if in, ok := input.(*s3.HeadObjectInput); ok && *in.Key == "p/1" {
+			return awsError("NotFound", 404)
+		}
+		return nil
+	}
+	c := NewS3CopierWithClient(mock, WithContinueOnError(true))
+
+	err := c.CopyWithPrefix("src", "dest", "p/")
+	var copyErrors CopyErrors
+	if !errors.As(err, &copyErrors) {
+		t.Fatalf("CopyWithPrefix returned %v, want CopyErrors", err)
+	}
+	if !errors.Is(err, ErrSourceNotFound) {
+		t.Errorf("errors.Is(%v, ErrSourceNotFound) = false, want true", err)
+	}
+	if errors.Is(err, ErrAccessDenied) {
+		t.Errorf("errors.Is(%v, ErrAccessDenied) = true, want false", err)
+	}
+}
@@ -0,0 +1,39 @@
+package s3copier
+
+// Operation names passed to request decorators.
+const (
+	OP_HEAD_OBJECT               = "HeadObject"
+	OP_COPY_OBJECT               = "CopyObject"
+	OP_CREATE_MULTIPART_UPLOAD   = "CreateMultipartUpload"
+	OP_UPLOAD_PART_COPY          = "UploadPartCopy"
+	OP_COMPLETE_MULTIPART_UPLOAD = "CompleteMultipartUpload"
+	OP_ABORT_MULTIPART_UPLOAD    = "AbortMultipartUpload"
+	OP_LIST_OBJECTS_V2           = "ListObjectsV2"
+	OP_PUT_OBJECT                = "PutObject"
+	OP_GET_OBJECT                = "GetObject"
+	OP_DELETE_OBJECT             = "DeleteObject"
+	OP_DELETE_OBJECTS            = "DeleteObjects"
+	OP_GET_OBJECT_TAGGING        = "GetObjectTagging"
+	OP_GET_OBJECT_ACL            = "GetObjectAcl"
+	OP_LIST_MULTIPART_UPLOADS    = "ListMultipartUploads"
+	OP_LIST_PARTS                = "ListParts"
+)
+
+// RequestDecorator modifies the input of an S3 request before it is sent.
+// input is the pointer to the SDK input type of op, e.g. *s3.CopyObjectInput for OP_COPY_OBJECT.
+type RequestDecorator func(op string, input interface{})
+
+// WithRequestDecorator adds a decorator invoked before each request the copier sends,
+// to set any field the SDK supports which has no dedicated option.
+// Decorators run in the order they are added, after the options have filled in the input.
+func WithRequestDecorator(fn RequestDecorator) Option {
+	return func(c *S3Copier) {
+		c.decorators = append(c.decorators, fn)
+	}
+}
+
+func (c *S3Copier) decorate(op string, input interface{}) {
+	for _, d := range c.decorators {
+		d(op, input)
+	}
+}
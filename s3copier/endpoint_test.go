@@ -0,0 +1,63 @@
+package s3copier
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// S3 互換ストレージの代わりに httptest のサーバーに path-style でリクエストする
+func TestCustomEndpointWithPathStyle(t *testing.T) {
+	type received struct {
+		method, host, path, copySource string
+	}
+	var mu sync.Mutex
+	var requests []received
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, received{r.Method, r.Host, r.URL.Path, r.Header.Get("X-Amz-Copy-Source")})
+		mu.Unlock()
+		switch r.Method {
+		case http.MethodHead:
+			w.Header().Set("Content-Length", "10")
+			w.Header().Set("ETag", `"0123456789abcdef0123456789abcdef"`)
+			w.Header().Set("Last-Modified", "Wed, 01 Jan 2020 00:00:00 GMT")
+		case http.MethodPut:
+			w.Write([]byte(`<CopyObjectResult><ETag>"0123456789abcdef0123456789abcdef"</ETag><LastModified>2020-01-01T00:00:00.000Z</LastModified></CopyObjectResult>`))
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer server.Close()
+
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:           aws.String("us-east-1"),
+		Endpoint:         aws.String(server.URL),
+		S3ForcePathStyle: aws.Bool(true),
+		Credentials:      credentials.NewStaticCredentials("AKID", "SECRET", ""),
+	}))
+	c := NewS3Copier(sess)
+
+	if err := c.CopyTo(NewS3Object("src-bucket", "dir/a.txt"), NewS3Object("dest-bucket", "dir/a.txt")); err != nil {
+		t.Fatalf("CopyTo failed: %v", err)
+	}
+	serverURL, _ := url.Parse(server.URL)
+	want := []received{
+		{http.MethodHead, serverURL.Host, "/src-bucket/dir/a.txt", ""},
+		{http.MethodPut, serverURL.Host, "/dest-bucket/dir/a.txt", "src-bucket/dir/a.txt"},
+	}
+	if len(requests) != len(want) {
+		t.Fatalf("the server received %+v, want %+v", requests, want)
+	}
+	for i := range want {
+		if requests[i] != want[i] {
+			t.Errorf("request %d is %+v, want %+v", i, requests[i], want[i])
+		}
+	}
+}
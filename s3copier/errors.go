@@ -0,0 +1,83 @@
+package s3copier
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+// Errors the copy of an object can fail with, to be checked with errors.Is.
+// The error of the SDK is still available with errors.As, e.g. as awserr.RequestFailure.
+var (
+	ErrSourceNotFound = errors.New("s3copier: source not found")
+	ErrAccessDenied   = errors.New("s3copier: access denied")
+	// ErrPartLimitExceeded is returned when S3 rejects a copy for exceeding its size or part limits,
+	// e.g. with EntityTooLarge
+	ErrPartLimitExceeded = errors.New("s3copier: part limit exceeded")
+)
+
+// objectError is an error of the object at path, which is also kind for errors.Is.
+type objectError struct {
+	path string
+	kind error
+	err  error
+}
+
+func (e *objectError) Error() string {
+	return fmt.Sprintf("%s: %v: %v", e.path, e.kind, e.err)
+}
+
+func (e *objectError) Is(target error) bool {
+	return target == e.kind
+}
+
+func (e *objectError) Unwrap() error {
+	return e.err
+}
+
+// classifyError wraps err of a request for obj with the error kind it corresponds to.
+func classifyError(obj *S3Object, err error) error {
+	var kind error
+	switch {
+	case isNotFound(err) && (errorCode(err) == "NotFound" || errorCode(err) == "NoSuchKey"):
+		// NoSuchBucket なども 404 になるので code まで見る
+		kind = ErrSourceNotFound
+	case statusCode(err) == http.StatusForbidden:
+		kind = ErrAccessDenied
+	case isLimitExceeded(err):
+		kind = ErrPartLimitExceeded
+	default:
+		return fmt.Errorf("%s: %w", obj.bucketKeyPath(), err)
+	}
+	return &objectError{path: obj.bucketKeyPath(), kind: kind, err: err}
+}
+
+// isLimitExceeded reports whether S3 rejected a request because the object or a part is too large,
+// or there are too many parts.
+func isLimitExceeded(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	if !ok {
+		return false
+	}
+	switch aerr.Code() {
+	case "EntityTooLarge":
+		return true
+	case "InvalidRequest":
+		// e.g. "The specified copy source is larger than the maximum allowable size for a copy source: 5368709120"
+		return strings.Contains(aerr.Message(), "maximum allowable size")
+	case "InvalidArgument":
+		// e.g. "Part number must be an integer between 1 and 10000, inclusive"
+		return strings.Contains(aerr.Message(), "Part number must be")
+	}
+	return false
+}
+
+func errorCode(err error) string {
+	if aerr, ok := err.(awserr.Error); ok {
+		return aerr.Code()
+	}
+	return ""
+}
@@ -0,0 +1,89 @@
+package s3copier
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+func TestCopyToErrorKinds(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		kind error
+	}{
+		{name: "not found", err: awsError("NotFound", 404), kind: ErrSourceNotFound},
+		{name: "forbidden", err: awsError("Forbidden", 403), kind: ErrAccessDenied},
+		{name: "no such bucket", err: awsError("NoSuchBucket", 404)},
+		{name: "internal error", err: awsError("InternalError", 500)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := newMockS3()
+			mock.put("src", "key", 1)
+			mock.hook = func(ctx aws.Context, op string, input interface{}) error {
+				if op == OP_HEAD_OBJECT {
+					return tt.err
+				}
+				return nil
+			}
+			c := NewS3CopierWithClient(mock, WithMaxRetries(0))
+
+			err := c.CopyTo(NewS3Object("src", "key"), NewS3Object("dest", "key"))
+			if err == nil || !strings.Contains(err.Error(), "src/key") {
+				t.Fatalf("CopyTo returned %v, want an error of src/key", err)
+			}
+			for _, kind := range []error{ErrSourceNotFound, ErrAccessDenied} {
+				if got, want := errors.Is(err, kind), kind == tt.kind; got != want {
+					t.Errorf("errors.Is(%v, %v) = %v, want %v", err, kind, got, want)
+				}
+			}
+			var failure awserr.RequestFailure
+			if !errors.As(err, &failure) || failure.StatusCode() != tt.err.(awserr.RequestFailure).StatusCode() {
+				t.Errorf("CopyTo returned %v, want the SDK error to be available with errors.As", err)
+			}
+		})
+	}
+}
+
+func TestCopyToLimitErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		op    string
+		size  int64
+		err   error
+		limit bool
+	}{
+		{name: "entity too large", op: OP_UPLOAD_PART_COPY, size: 20 * ONE_MB, err: awsError("EntityTooLarge", 400), limit: true},
+		{name: "copy source too large", op: OP_COPY_OBJECT, size: 1, limit: true,
+			err: awserr.NewRequestFailure(awserr.New("InvalidRequest", "The specified copy source is larger than the maximum allowable size for a copy source: 5368709120", nil), 400, "request-id")},
+		{name: "too many parts", op: OP_UPLOAD_PART_COPY, size: 20 * ONE_MB, limit: true,
+			err: awserr.NewRequestFailure(awserr.New("InvalidArgument", "Part number must be an integer between 1 and 10000, inclusive", nil), 400, "request-id")},
+		{name: "other invalid request", op: OP_COPY_OBJECT, size: 1,
+			err: awserr.NewRequestFailure(awserr.New("InvalidRequest", "The authorization mechanism you have provided is not supported.", nil), 400, "request-id")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := newMockS3()
+			mock.put("src", "key", tt.size)
+			mock.hook = func(ctx aws.Context, op string, input interface{}) error {
+				if op == tt.op {
+					return tt.err
+				}
+				return nil
+			}
+			c := NewS3CopierWithClient(mock, WithMaxRetries(0))
+
+			err := c.CopyTo(NewS3Object("src", "key"), NewS3Object("dest", "key"))
+			if err == nil {
+				t.Fatal("CopyTo succeeded")
+			}
+			if got := errors.Is(err, ErrPartLimitExceeded); got != tt.limit {
+				t.Errorf("errors.Is(%v, ErrPartLimitExceeded) = %v, want %v", err, got, tt.limit)
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package s3copier_test
+
+import (
+	"log"
+	"s3test/s3copier"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// Copy a single known object without listing a prefix.
+func ExampleS3Copier_CopyTo() {
+	sess := session.Must(session.NewSession(&aws.Config{Region: aws.String("ap-northeast-1")}))
+	copier := s3copier.NewS3Copier(sess)
+
+	err := copier.CopyTo(s3copier.NewS3Object("src", "a/b.mp4"), s3copier.NewS3Object("dst", "a/b.mp4"))
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,99 @@
+package s3copier
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// WithSkipExisting makes the copier head the destination of each object and skip the copy
+// when it already exists with the same ContentLength and ETag (only the size is compared
+// for multipart objects, whose ETag depends on the part size).
+// This costs a HeadObject per object; see WithPreCopyExistenceIndex for large runs.
+func WithSkipExisting(enabled bool) Option {
+	return func(c *S3Copier) {
+		c.skipExisting = enabled
+	}
+}
+
+// WithPreCopyExistenceIndex makes CopyWithPrefix list the destination prefix once before copying
+// and skip source objects that already exist there unchanged, as WithSkipExisting does per object.
+// This keeps every destination key in memory, but replaces a HeadObject per object with
+// one LIST request per 1000 objects.
+// With WithKeyMapper, the destination prefix listed is the prefix mapped by the key mapper.
+func WithPreCopyExistenceIndex(enabled bool) Option {
+	return func(c *S3Copier) {
+		c.useExistenceIndex = enabled
+	}
+}
+
+type existingObject struct {
+	size int64
+	etag string
+}
+
+// existenceIndex maps a destination key to its size and ETag. A nil index contains nothing.
+type existenceIndex map[string]existingObject
+
+func (c *S3Copier) buildExistenceIndex(ctx context.Context, bucket, prefix string) (existenceIndex, error) {
+	index := existenceIndex{}
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	}
+	c.decorate(OP_LIST_OBJECTS_V2, input)
+	err := c.destClient.ListObjectsV2PagesWithContext(ctx, input, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, obj := range page.Contents {
+			index[*obj.Key] = existingObject{
+				size: aws.Int64Value(obj.Size),
+				etag: aws.StringValue(obj.ETag),
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// contains reports whether src already exists at destKey unchanged.
+func (index existenceIndex) contains(destKey string, src *s3.Object) bool {
+	existing, ok := index[destKey]
+	return ok && isSameObject(existing.size, existing.etag, aws.Int64Value(src.Size), aws.StringValue(src.ETag))
+}
+
+// destinationHead heads dest, returning nil when it doesn't exist yet.
+func (c *S3Copier) destinationHead(ctx context.Context, dest *S3Object) (*s3.HeadObjectOutput, error) {
+	head, err := c.headObject(ctx, c.destClient, dest, c.sseCustomerKey)
+	if isNotFound(err) {
+		return nil, nil
+	}
+	return head, err
+}
+
+// isUnchanged reports whether the destination has the same content as the source.
+func isUnchanged(srcHead, destHead *s3.HeadObjectOutput) bool {
+	return isSameObject(
+		aws.Int64Value(destHead.ContentLength), aws.StringValue(destHead.ETag),
+		aws.Int64Value(srcHead.ContentLength), aws.StringValue(srcHead.ETag),
+	)
+}
+
+// isSameObject compares the size and ETag of two objects.
+// The ETag of a multipart object depends on its part size, so only the size is compared for those.
+func isSameObject(size1 int64, etag1 string, size2 int64, etag2 string) bool {
+	if size1 != size2 {
+		return false
+	}
+	if isMultipartETag(etag1) || isMultipartETag(etag2) {
+		return true
+	}
+	return etag1 == etag2
+}
+
+func isMultipartETag(etag string) bool {
+	return strings.Contains(etag, "-")
+}
@@ -0,0 +1,56 @@
+package s3copier
+
+import "testing"
+
+func TestSkipExisting(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(mock *mockS3, src *mockObject)
+		skipped bool
+	}{
+		{
+			name:  "destination missing",
+			setup: func(mock *mockS3, src *mockObject) {},
+		},
+		{
+			name: "destination identical",
+			setup: func(mock *mockS3, src *mockObject) {
+				dest := mock.put("dest", "p/key", src.size)
+				dest.etag = src.etag
+			},
+			skipped: true,
+		},
+		{
+			name: "destination of a different size",
+			setup: func(mock *mockS3, src *mockObject) {
+				mock.put("dest", "p/key", src.size+1)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := newMockS3()
+			src := mock.put("src", "p/key", 100)
+			tt.setup(mock, src)
+			c := NewS3CopierWithClient(mock, WithSkipExisting(true))
+
+			result, err := c.CopyWithPrefixResult("src", "dest", "p/")
+			if err != nil {
+				t.Fatalf("CopyWithPrefixResult failed: %v", err)
+			}
+			copies := 1
+			if tt.skipped {
+				copies = 0
+			}
+			if n := mock.count(OP_COPY_OBJECT); n != copies {
+				t.Errorf("CopyObject is called %d times, want %d", n, copies)
+			}
+			if result.ObjectsCopied != copies || result.Skipped != 1-copies {
+				t.Errorf("%d objects copied and %d skipped, want %d and %d", result.ObjectsCopied, result.Skipped, copies, 1-copies)
+			}
+			if dest := mock.object("dest", "p/key"); dest == nil || dest.size != 100 {
+				t.Errorf("destination is %+v, want 100 bytes", dest)
+			}
+		})
+	}
+}
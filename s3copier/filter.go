@@ -0,0 +1,58 @@
+package s3copier
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// WithIncludeFilter makes CopyWithPrefix copy only the keys matching re, e.g. `\.mp4$`.
+// With several include filters, a key matching any of them is copied.
+func WithIncludeFilter(re *regexp.Regexp) Option {
+	return func(c *S3Copier) {
+		c.includeFilters = append(c.includeFilters, re)
+	}
+}
+
+// WithExcludeFilter makes CopyWithPrefix leave out the keys matching re, e.g. `/tmp/`.
+// Exclude filters win over include filters.
+// Filtered keys are not copied nor counted as skipped, as if they were not listed.
+func WithExcludeFilter(re *regexp.Regexp) Option {
+	return func(c *S3Copier) {
+		c.excludeFilters = append(c.excludeFilters, re)
+	}
+}
+
+// WithModifiedAfter makes CopyWithPrefix copy only the objects whose LastModified in the listing is
+// after t, for incremental syncs. The other objects are filtered out without even a HeadObject.
+// CopyKeys has no listing, so it copies every key regardless of t.
+func WithModifiedAfter(t time.Time) Option {
+	return func(c *S3Copier) {
+		c.modifiedAfter = t
+	}
+}
+
+func (c *S3Copier) isModifiedAfter(obj *s3.Object) bool {
+	if c.modifiedAfter.IsZero() || obj.LastModified == nil {
+		return true
+	}
+	return obj.LastModified.After(c.modifiedAfter)
+}
+
+func (c *S3Copier) matchesFilters(key string) bool {
+	for _, re := range c.excludeFilters {
+		if re.MatchString(key) {
+			return false
+		}
+	}
+	if len(c.includeFilters) == 0 {
+		return true
+	}
+	for _, re := range c.includeFilters {
+		if re.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
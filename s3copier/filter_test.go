@@ -0,0 +1,77 @@
+package s3copier
+
+import (
+	"reflect"
+	"regexp"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestFilters(t *testing.T) {
+	mock := newMockS3()
+	for _, key := range []string{"p/a.mp4", "p/b.mp4", "p/tmp/c.mp4", "p/d.m3u8", "p/e.txt"} {
+		mock.put("src", key, 10)
+	}
+	var completed []int
+	c := NewS3CopierWithClient(mock,
+		WithIncludeFilter(regexp.MustCompile(`\.mp4$`)),
+		WithIncludeFilter(regexp.MustCompile(`\.m3u8$`)),
+		WithExcludeFilter(regexp.MustCompile(`/tmp/`)),
+		WithProgress(func(ev ProgressEvent) {
+			completed = append(completed, ev.Completed)
+		}),
+	)
+
+	result, err := c.CopyWithPrefixResult("src", "dest", "p/")
+	if err != nil {
+		t.Fatalf("CopyWithPrefixResult failed: %v", err)
+	}
+	var copied []string
+	for _, key := range []string{"p/a.mp4", "p/b.mp4", "p/tmp/c.mp4", "p/d.m3u8", "p/e.txt"} {
+		if mock.object("dest", key) != nil {
+			copied = append(copied, key)
+		}
+	}
+	if want := []string{"p/a.mp4", "p/b.mp4", "p/d.m3u8"}; !reflect.DeepEqual(copied, want) {
+		t.Errorf("%v are copied, want %v", copied, want)
+	}
+	// filter で落ちた key は skip にも数えない
+	if result.ObjectsCopied != 3 || result.Skipped != 0 || result.BytesCopied != 30 {
+		t.Errorf("result is %d copied, %d skipped, %d bytes; want 3 copied, 0 skipped, 30 bytes", result.ObjectsCopied, result.Skipped, result.BytesCopied)
+	}
+	sort.Ints(completed)
+	if want := []int{1, 2, 3}; !reflect.DeepEqual(completed, want) {
+		t.Errorf("progress is reported with Completed %v, want %v", completed, want)
+	}
+	if n := mock.count(OP_HEAD_OBJECT); n != 3 {
+		t.Errorf("HeadObject is called %d times, want only for the 3 filtered keys", n)
+	}
+}
+
+func TestWithModifiedAfter(t *testing.T) {
+	mock := newMockS3()
+	since := time.Now().Add(-time.Minute)
+	mock.putKeys("src", "p/old", 3, 1)
+	for _, key := range mock.putKeys("src", "p/new", 2, 1) {
+		mock.object("src", key).lastModified = time.Now()
+	}
+	c := NewS3CopierWithClient(mock, WithModifiedAfter(since))
+
+	result, err := c.CopyWithPrefixResult("src", "dest", "p/")
+	if err != nil {
+		t.Fatalf("CopyWithPrefixResult failed: %v", err)
+	}
+	if result.ObjectsCopied != 2 || result.Skipped != 0 {
+		t.Errorf("%d objects copied and %d skipped, want 2 and 0", result.ObjectsCopied, result.Skipped)
+	}
+	for _, key := range []string{"p/new0", "p/new1"} {
+		if mock.object("dest", key) == nil {
+			t.Errorf("dest/%s is not copied", key)
+		}
+	}
+	// 古い object は head もしない
+	if n := mock.count(OP_HEAD_OBJECT); n != 2 {
+		t.Errorf("HeadObject is called %d times, want 2", n)
+	}
+}
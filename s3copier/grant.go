@@ -0,0 +1,90 @@
+package s3copier
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Grantee URIs of the predefined S3 groups.
+// https://docs.aws.amazon.com/AmazonS3/latest/dev/acl-overview.html#specifying-grantee-predefined-groups
+const (
+	GROUP_ALL_USERS           = "http://acs.amazonaws.com/groups/global/AllUsers"
+	GROUP_AUTHENTICATED_USERS = "http://acs.amazonaws.com/groups/global/AuthenticatedUsers"
+	GROUP_LOG_DELIVERY        = "http://acs.amazonaws.com/groups/s3/LogDelivery"
+)
+
+type grantPermission int
+
+const (
+	grantRead grantPermission = iota
+	grantReadACP
+	grantWriteACP
+	grantFullControl
+)
+
+func groupGrantee(uri string) string {
+	return fmt.Sprintf("uri=\"%s\"", uri)
+}
+
+// withGrant appends grantees to the x-amz-grant-* header of the requests creating destination objects.
+func withGrant(permission grantPermission, grantee []string) Option {
+	return WithRequestDecorator(func(op string, input interface{}) {
+		var fields [4]**string
+		switch in := input.(type) {
+		case *s3.CopyObjectInput:
+			fields = [4]**string{&in.GrantRead, &in.GrantReadACP, &in.GrantWriteACP, &in.GrantFullControl}
+		case *s3.CreateMultipartUploadInput:
+			fields = [4]**string{&in.GrantRead, &in.GrantReadACP, &in.GrantWriteACP, &in.GrantFullControl}
+		default:
+			return
+		}
+		field := fields[permission]
+		values := grantee
+		if *field != nil {
+			values = append([]string{**field}, grantee...)
+		}
+		*field = aws.String(strings.Join(values, ", "))
+	})
+}
+
+// WithGrantRead adds grantees (e.g. `id="..."`, `uri="..."`) to GrantRead of the destination objects.
+func WithGrantRead(grantee ...string) Option {
+	return withGrant(grantRead, grantee)
+}
+
+// WithGrantReadACP adds grantees to GrantReadACP of the destination objects.
+func WithGrantReadACP(grantee ...string) Option {
+	return withGrant(grantReadACP, grantee)
+}
+
+// WithGrantWriteACP adds grantees to GrantWriteACP of the destination objects.
+func WithGrantWriteACP(grantee ...string) Option {
+	return withGrant(grantWriteACP, grantee)
+}
+
+// WithGrantFullControl adds grantees to GrantFullControl of the destination objects.
+func WithGrantFullControl(grantee ...string) Option {
+	return withGrant(grantFullControl, grantee)
+}
+
+// WithACLReadGrantsForLogDelivery grants READ and READ_ACP to the log-delivery group.
+func WithACLReadGrantsForLogDelivery() Option {
+	g := groupGrantee(GROUP_LOG_DELIVERY)
+	return func(c *S3Copier) {
+		WithGrantRead(g)(c)
+		WithGrantReadACP(g)(c)
+	}
+}
+
+// WithACLReadGrantsForAllUsers grants READ to everyone (anonymous access).
+func WithACLReadGrantsForAllUsers() Option {
+	return WithGrantRead(groupGrantee(GROUP_ALL_USERS))
+}
+
+// WithACLReadGrantsForAuthenticatedUsers grants READ to any authenticated AWS account.
+func WithACLReadGrantsForAuthenticatedUsers() Option {
+	return WithGrantRead(groupGrantee(GROUP_AUTHENTICATED_USERS))
+}
@@ -0,0 +1,70 @@
+package s3copier
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func TestGroupGrants(t *testing.T) {
+	tests := []struct {
+		name         string
+		opt          Option
+		grantRead    string
+		grantReadACP string
+	}{
+		{
+			name:         "log delivery",
+			opt:          WithACLReadGrantsForLogDelivery(),
+			grantRead:    `uri="http://acs.amazonaws.com/groups/s3/LogDelivery"`,
+			grantReadACP: `uri="http://acs.amazonaws.com/groups/s3/LogDelivery"`,
+		},
+		{
+			name:      "all users",
+			opt:       WithACLReadGrantsForAllUsers(),
+			grantRead: `uri="http://acs.amazonaws.com/groups/global/AllUsers"`,
+		},
+		{
+			name:      "authenticated users",
+			opt:       WithACLReadGrantsForAuthenticatedUsers(),
+			grantRead: `uri="http://acs.amazonaws.com/groups/global/AuthenticatedUsers"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := newMockS3()
+			mock.put("src", "key", 1)
+			c := NewS3CopierWithClient(mock, tt.opt)
+
+			if err := c.CopyTo(NewS3Object("src", "key"), NewS3Object("dest", "key")); err != nil {
+				t.Fatalf("CopyTo failed: %v", err)
+			}
+			input := mock.inputs(OP_COPY_OBJECT)[0].(*s3.CopyObjectInput)
+			if got := aws.StringValue(input.GrantRead); got != tt.grantRead {
+				t.Errorf("GrantRead = %q, want %q", got, tt.grantRead)
+			}
+			if got := aws.StringValue(input.GrantReadACP); got != tt.grantReadACP {
+				t.Errorf("GrantReadACP = %q, want %q", got, tt.grantReadACP)
+			}
+			if input.GrantWriteACP != nil || input.GrantFullControl != nil {
+				t.Errorf("GrantWriteACP = %v, GrantFullControl = %v, want nil", input.GrantWriteACP, input.GrantFullControl)
+			}
+		})
+	}
+}
+
+func TestGrantsAreAppended(t *testing.T) {
+	mock := newMockS3()
+	mock.put("src", "key", 1)
+	c := NewS3CopierWithClient(mock, WithGrantRead(`id="owner"`), WithACLReadGrantsForAllUsers())
+
+	if err := c.CopyTo(NewS3Object("src", "key"), NewS3Object("dest", "key")); err != nil {
+		t.Fatalf("CopyTo failed: %v", err)
+	}
+	input := mock.inputs(OP_COPY_OBJECT)[0].(*s3.CopyObjectInput)
+	want := `id="owner", uri="http://acs.amazonaws.com/groups/global/AllUsers"`
+	if got := aws.StringValue(input.GrantRead); got != want {
+		t.Errorf("GrantRead = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,80 @@
+package s3copier
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// WithObjectCopyHedging issues another attempt of an UploadPartCopy which hasn't completed within
+// threshold, up to maxHedges extra attempts per part, and takes whichever finishes first.
+// The others are cancelled. This is safe because every attempt copies the same range to the same
+// part number, and it cuts the tail latency of straggling parts at the cost of some extra requests.
+func WithObjectCopyHedging(threshold time.Duration, maxHedges int) Option {
+	if threshold <= 0 {
+		panic(fmt.Sprintf("s3copier: hedging threshold must be positive, got %v", threshold))
+	}
+	if maxHedges < 0 {
+		panic(fmt.Sprintf("s3copier: max hedges must not be negative, got %d", maxHedges))
+	}
+	return func(c *S3Copier) {
+		c.hedging = &hedging{threshold: threshold, maxHedges: maxHedges}
+	}
+}
+
+type hedging struct {
+	threshold time.Duration
+	maxHedges int
+}
+
+type hedgeResult struct {
+	output *s3.UploadPartCopyOutput
+	err    error
+}
+
+func (c *S3Copier) hedgedUploadPartCopy(ctx context.Context, input *s3.UploadPartCopyInput) (*s3.UploadPartCopyOutput, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	// 負けた方のリクエストはここでキャンセルされる
+	defer cancel()
+
+	results := make(chan hedgeResult, c.hedging.maxHedges+1)
+	launched := 0
+	launch := func() {
+		launched++
+		// SDK が input に MD5 などを書き込むので試行ごとにコピーを渡す
+		in := *input
+		go func() {
+			output, err := c.destClient.UploadPartCopyWithContext(ctx, &in)
+			results <- hedgeResult{output: output, err: err}
+		}()
+	}
+
+	launch()
+	timer := time.NewTimer(c.hedging.threshold)
+	defer timer.Stop()
+
+	var lastErr error
+	for received := 0; received < launched; {
+		select {
+		case r := <-results:
+			received++
+			if r.err == nil {
+				return r.output, nil
+			}
+			lastErr = r.err
+		case <-timer.C:
+			if launched <= c.hedging.maxHedges {
+				// 最初の試行は retryer.do で待っているので、追加の試行の分だけ待つ
+				if err := c.retryer.wait(ctx); err != nil {
+					// キャンセルされたので投げ済みの試行の結果を待つ
+					continue
+				}
+				launch()
+				timer.Reset(c.hedging.threshold)
+			}
+		}
+	}
+	return nil, lastErr
+}
@@ -0,0 +1,82 @@
+package s3copier
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"golang.org/x/time/rate"
+)
+
+func TestHedgingWinsOverStalledPart(t *testing.T) {
+	mock := newMockS3()
+	mock.put("src", "key", 20*ONE_MB)
+	var calls int32
+	mock.hook = func(ctx aws.Context, op string, input interface{}) error {
+		if op == OP_UPLOAD_PART_COPY && atomic.AddInt32(&calls, 1) == 1 {
+			// 最初の試行は応答しない
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	}
+	c := NewS3CopierWithClient(mock, WithObjectCopyHedging(10*time.Millisecond, 1), WithPartConcurrency(1))
+
+	if err := c.CopyTo(NewS3Object("src", "key"), NewS3Object("dest", "key")); err != nil {
+		t.Fatalf("CopyTo failed: %v", err)
+	}
+	if n := mock.count(OP_UPLOAD_PART_COPY); n != 3 {
+		t.Errorf("UploadPartCopy is called %d times, want 3 (2 parts and a hedge)", n)
+	}
+	if dest := mock.object("dest", "key"); dest == nil || dest.size != 20*ONE_MB {
+		t.Errorf("destination is %+v, want %d bytes", dest, 20*ONE_MB)
+	}
+}
+
+func TestWithObjectCopyHedgingValidates(t *testing.T) {
+	tests := []struct {
+		threshold time.Duration
+		maxHedges int
+	}{
+		{0, 1},
+		{-time.Second, 1},
+		{time.Second, -1},
+		{time.Second, -2},
+	}
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("WithObjectCopyHedging(%v, %d) doesn't panic", tt.threshold, tt.maxHedges)
+				}
+			}()
+			WithObjectCopyHedging(tt.threshold, tt.maxHedges)
+		}()
+	}
+	// 0 は hedge しないだけで有効
+	WithObjectCopyHedging(time.Second, 0)
+}
+
+func TestHedgedAttemptsAreRateLimited(t *testing.T) {
+	mock := newMockS3()
+	mock.hook = func(ctx aws.Context, op string, input interface{}) error {
+		// どの試行も終わらない
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	c := NewS3CopierWithClient(mock, WithObjectCopyHedging(5*time.Millisecond, 3))
+	// 追加の試行 2回分の token しかない
+	c.retryer.limiter = rate.NewLimiter(rate.Every(time.Hour), 2)
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	if _, err := c.hedgedUploadPartCopy(ctx, &s3.UploadPartCopyInput{UploadId: aws.String("upload-1")}); err == nil {
+		t.Fatal("hedgedUploadPartCopy succeeded, want the context error")
+	}
+	if n := mock.count(OP_UPLOAD_PART_COPY); n != 3 {
+		t.Errorf("UploadPartCopy is attempted %d times, want 3 (the first one and 2 hedges with tokens)", n)
+	}
+}
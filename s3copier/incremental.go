@@ -0,0 +1,32 @@
+package s3copier
+
+import (
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+// WithCopySourceIfModifiedSinceFromDestination copies an object only when the source is newer
+// than the existing destination object. The destination is headed per key and its LastModified is
+// sent as CopySourceIfModifiedSince, so S3 itself refuses to copy unchanged objects (412),
+// which are counted as skipped.
+func WithCopySourceIfModifiedSinceFromDestination(enabled bool) Option {
+	return func(c *S3Copier) {
+		c.ifModifiedSinceDestination = enabled
+	}
+}
+
+func statusCode(err error) int {
+	if reqErr, ok := err.(awserr.RequestFailure); ok {
+		return reqErr.StatusCode()
+	}
+	return 0
+}
+
+func isNotFound(err error) bool {
+	return statusCode(err) == http.StatusNotFound
+}
+
+func isPreconditionFailed(err error) bool {
+	return statusCode(err) == http.StatusPreconditionFailed
+}
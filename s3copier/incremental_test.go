@@ -0,0 +1,67 @@
+package s3copier
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func TestCopySourceIfModifiedSinceFromDestination(t *testing.T) {
+	for _, size := range []int64{1, 20 * ONE_MB} {
+		mock := newMockS3()
+		now := time.Now()
+		// destination の方が新しい
+		mock.put("src", "p/unchanged", size).lastModified = now.Add(-2 * time.Hour)
+		mock.put("dest", "p/unchanged", size).lastModified = now.Add(-time.Hour)
+		// source の方が新しい
+		mock.put("src", "p/changed", size).lastModified = now
+		mock.put("dest", "p/changed", size).lastModified = now.Add(-time.Hour)
+		mock.put("src", "p/new", size)
+		c := NewS3CopierWithClient(mock, WithCopySourceIfModifiedSinceFromDestination(true))
+
+		result, err := c.CopyWithPrefixResult("src", "dest", "p/")
+		if err != nil {
+			t.Fatalf("CopyWithPrefixResult of %d bytes failed: %v", size, err)
+		}
+		if result.ObjectsCopied != 2 || result.Skipped != 1 {
+			t.Errorf("%d objects of %d bytes copied and %d skipped, want 2 and 1", result.ObjectsCopied, size, result.Skipped)
+		}
+		if len(result.SkippedObjects) != 1 || result.SkippedObjects[0] != (SkippedObject{Key: "p/unchanged", Reason: SKIP_REASON_NOT_MODIFIED}) {
+			t.Errorf("skipped objects are %+v, want p/unchanged as not modified", result.SkippedObjects)
+		}
+		if got := mock.object("dest", "p/changed").lastModified; !got.After(now) {
+			t.Errorf("dest/p/changed of %d bytes is not copied again", size)
+		}
+		if mock.object("dest", "p/new") == nil {
+			t.Errorf("dest/p/new of %d bytes is not copied", size)
+		}
+
+		// 条件は destination の LastModified で、destination がない key には付けない
+		conditions := map[string]*time.Time{}
+		for _, input := range mock.inputs(OP_COPY_OBJECT) {
+			in := input.(*s3.CopyObjectInput)
+			conditions[*in.Key] = in.CopySourceIfModifiedSince
+		}
+		for _, input := range mock.inputs(OP_UPLOAD_PART_COPY) {
+			in := input.(*s3.UploadPartCopyInput)
+			conditions[*in.Key] = in.CopySourceIfModifiedSince
+		}
+		wantConditions := map[string]time.Time{"p/unchanged": now.Add(-time.Hour), "p/changed": now.Add(-time.Hour)}
+		for key, want := range wantConditions {
+			if got := conditions[key]; got == nil || !got.Equal(want) {
+				t.Errorf("%s of %d bytes is copied with CopySourceIfModifiedSince %v, want %v", key, size, aws.TimeValue(got), want)
+			}
+		}
+		if got := conditions["p/new"]; got != nil {
+			t.Errorf("p/new of %d bytes is copied with CopySourceIfModifiedSince %v, want none", size, *got)
+		}
+		if size > FIVE_MB {
+			aborts := mock.inputs(OP_ABORT_MULTIPART_UPLOAD)
+			if len(aborts) != 1 || *aborts[0].(*s3.AbortMultipartUploadInput).Key != "p/unchanged" {
+				t.Errorf("%d uploads are aborted, want the one of p/unchanged", len(aborts))
+			}
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package s3copier
+
+// Logger receives the log messages of the copier. Formats are fmt style without a trailing newline.
+// Adapters for logrus, zap etc. are a few lines each.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// WithLogger sets the Logger of the copier. Nothing is logged by default.
+func WithLogger(logger Logger) Option {
+	return func(c *S3Copier) {
+		c.logger = logger
+	}
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debugf(format string, args ...interface{}) {}
+func (nopLogger) Infof(format string, args ...interface{})  {}
+func (nopLogger) Errorf(format string, args ...interface{}) {}
@@ -0,0 +1,53 @@
+package s3copier
+
+import "github.com/aws/aws-sdk-go/service/s3"
+
+// WithMetadataTransform rewrites the user metadata of each object while copying.
+// fn receives a copy of the source metadata, so it may modify the map freely.
+// When fn returns a non-nil map, it is set on the destination with MetadataDirective=REPLACE
+// (system metadata such as ContentType is carried over from the source explicitly).
+// Returning nil leaves the metadata untouched.
+func WithMetadataTransform(fn func(map[string]*string) map[string]*string) Option {
+	return func(c *S3Copier) {
+		c.metadataTransform = fn
+	}
+}
+
+// WithMetadataDirective sets the MetadataDirective of single part copies.
+// By default it is REPLACE only when WithMetadataTransform or WithContentTypeByExtension changes
+// something. With s3.MetadataDirectiveReplace, the metadata and the system metadata are always set
+// explicitly, e.g. to clear the user metadata with a transform returning an empty map.
+// With s3.MetadataDirectiveCopy, the metadata of the source is kept as is and the transform and
+// the ContentType mapping are not applied. Multipart copies always set the metadata when the upload
+// is created, following the same rules.
+func WithMetadataDirective(directive string) Option {
+	return func(c *S3Copier) {
+		c.metadataDirective = directive
+	}
+}
+
+// destinationMetadata returns the user metadata to set on the destination, nil when it is the one
+// of the source, and the ContentType.
+func (c *S3Copier) destinationMetadata(src *S3Object, srcHead *s3.HeadObjectOutput) (map[string]*string, *string) {
+	if c.metadataDirective == s3.MetadataDirectiveCopy {
+		return nil, srcHead.ContentType
+	}
+	return c.transformMetadata(srcHead.Metadata), c.contentTypeFor(src.key, srcHead)
+}
+
+// transformMetadata returns nil when there is nothing to replace.
+func (c *S3Copier) transformMetadata(metadata map[string]*string) map[string]*string {
+	if c.metadataTransform == nil {
+		return nil
+	}
+	// head の結果は共有されうるのでコピーを渡す
+	cloned := make(map[string]*string, len(metadata))
+	for k, v := range metadata {
+		if v != nil {
+			s := *v
+			v = &s
+		}
+		cloned[k] = v
+	}
+	return c.metadataTransform(cloned)
+}
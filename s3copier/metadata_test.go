@@ -0,0 +1,93 @@
+package s3copier
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func TestSystemMetadataIsPreserved(t *testing.T) {
+	tests := []struct {
+		name string
+		size int64
+		opts []Option
+	}{
+		{name: "single part", size: 1},
+		{name: "single part replacing the metadata", size: 1, opts: []Option{WithMetadataDirective(s3.MetadataDirectiveReplace)}},
+		{name: "single part with a transform", size: 1, opts: []Option{WithMetadataTransform(func(m map[string]*string) map[string]*string {
+			return m
+		})}},
+		{name: "multipart", size: 20 * ONE_MB},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := newMockS3()
+			src := mock.put("src", "index.html", tt.size)
+			src.contentType = "text/html"
+			src.cacheControl = "max-age=3600"
+			src.contentDisposition = "inline"
+			src.contentEncoding = "gzip"
+			src.contentLanguage = "ja"
+			src.metadata = map[string]*string{"Owner": aws.String("web")}
+			c := NewS3CopierWithClient(mock, tt.opts...)
+
+			if err := c.CopyTo(NewS3Object("src", "index.html"), NewS3Object("dest", "index.html")); err != nil {
+				t.Fatalf("CopyTo failed: %v", err)
+			}
+			dest := mock.object("dest", "index.html")
+			got := []string{dest.contentType, dest.cacheControl, dest.contentDisposition, dest.contentEncoding, dest.contentLanguage}
+			want := []string{"text/html", "max-age=3600", "inline", "gzip", "ja"}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("destination has ContentType, CacheControl, ContentDisposition, ContentEncoding, ContentLanguage %q, want %q", got, want)
+			}
+			if !reflect.DeepEqual(dest.metadata, src.metadata) {
+				t.Errorf("destination has metadata %v, want %v", aws.StringValueMap(dest.metadata), aws.StringValueMap(src.metadata))
+			}
+		})
+	}
+}
+
+func TestMetadataDirective(t *testing.T) {
+	clearMetadata := WithMetadataTransform(func(map[string]*string) map[string]*string {
+		return map[string]*string{}
+	})
+	tests := []struct {
+		name      string
+		size      int64
+		opts      []Option
+		directive string
+		metadata  map[string]*string
+	}{
+		{name: "replace single part", size: 1, opts: []Option{WithMetadataDirective(s3.MetadataDirectiveReplace), clearMetadata}, directive: s3.MetadataDirectiveReplace, metadata: map[string]*string{}},
+		{name: "replace multipart", size: 20 * ONE_MB, opts: []Option{WithMetadataDirective(s3.MetadataDirectiveReplace), clearMetadata}, metadata: map[string]*string{}},
+		{name: "copy single part", size: 1, opts: []Option{WithMetadataDirective(s3.MetadataDirectiveCopy), clearMetadata}, directive: s3.MetadataDirectiveCopy},
+		{name: "copy multipart", size: 20 * ONE_MB, opts: []Option{WithMetadataDirective(s3.MetadataDirectiveCopy), clearMetadata}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := newMockS3()
+			src := mock.put("src", "key", tt.size)
+			src.metadata = map[string]*string{"Owner": aws.String("web")}
+			c := NewS3CopierWithClient(mock, tt.opts...)
+
+			if err := c.CopyTo(NewS3Object("src", "key"), NewS3Object("dest", "key")); err != nil {
+				t.Fatalf("CopyTo failed: %v", err)
+			}
+			want := tt.metadata
+			if want == nil {
+				want = src.metadata
+			}
+			if dest := mock.object("dest", "key"); !reflect.DeepEqual(aws.StringValueMap(dest.metadata), aws.StringValueMap(want)) {
+				t.Errorf("destination has metadata %v, want %v", aws.StringValueMap(dest.metadata), aws.StringValueMap(want))
+			}
+			if tt.directive != "" {
+				input := mock.inputs(OP_COPY_OBJECT)[0].(*s3.CopyObjectInput)
+				if aws.StringValue(input.MetadataDirective) != tt.directive {
+					t.Errorf("CopyObject is sent with MetadataDirective %q, want %s", aws.StringValue(input.MetadataDirective), tt.directive)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package s3copier
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// MetricsRecorder receives the metrics of the copier, e.g. to export them as Prometheus counters.
+// The methods are called concurrently from the workers.
+type MetricsRecorder interface {
+	IncObjectsCopied()
+	AddBytesCopied(bytes int64)
+	IncRetries()
+	IncErrors()
+	// ObserveCopyDuration is called with the time each copied object took
+	ObserveCopyDuration(d time.Duration)
+}
+
+// WithMetrics sets the MetricsRecorder of the copier. Nothing is recorded by default.
+func WithMetrics(metrics MetricsRecorder) Option {
+	return func(c *S3Copier) {
+		c.metrics = metrics
+		c.retryer.metrics = metrics
+	}
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) IncObjectsCopied()                   {}
+func (nopMetrics) AddBytesCopied(bytes int64)          {}
+func (nopMetrics) IncRetries()                         {}
+func (nopMetrics) IncErrors()                          {}
+func (nopMetrics) ObserveCopyDuration(d time.Duration) {}
+
+// MemoryMetrics is a MetricsRecorder keeping the totals in memory.
+// Read the fields with atomic.LoadInt64 while a copy is running.
+type MemoryMetrics struct {
+	ObjectsCopied int64
+	BytesCopied   int64
+	Retries       int64
+	Errors        int64
+	// CopyDuration is the sum of the durations passed to ObserveCopyDuration
+	CopyDuration int64
+}
+
+func (m *MemoryMetrics) IncObjectsCopied()          { atomic.AddInt64(&m.ObjectsCopied, 1) }
+func (m *MemoryMetrics) AddBytesCopied(bytes int64) { atomic.AddInt64(&m.BytesCopied, bytes) }
+func (m *MemoryMetrics) IncRetries()                { atomic.AddInt64(&m.Retries, 1) }
+func (m *MemoryMetrics) IncErrors()                 { atomic.AddInt64(&m.Errors, 1) }
+func (m *MemoryMetrics) ObserveCopyDuration(d time.Duration) {
+	atomic.AddInt64(&m.CopyDuration, int64(d))
+}
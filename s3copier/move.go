@@ -0,0 +1,48 @@
+package s3copier
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// DeleteObjects で一度に消せる上限
+const DELETE_BATCH_SIZE = 1000
+
+// deleteSources deletes keys from bucket with a DeleteObjects and returns the keys which failed.
+func (c *S3Copier) deleteSources(ctx context.Context, bucket string, keys []string) []CopyError {
+	objects := make([]*s3.ObjectIdentifier, 0, len(keys))
+	for _, k := range keys {
+		objects = append(objects, &s3.ObjectIdentifier{Key: aws.String(k)})
+	}
+	input := &s3.DeleteObjectsInput{
+		Bucket: aws.String(bucket),
+		Delete: &s3.Delete{
+			Objects: objects,
+			// 失敗したものだけ返してもらう
+			Quiet: aws.Bool(true),
+		},
+	}
+	c.decorate(OP_DELETE_OBJECTS, input)
+	var output *s3.DeleteObjectsOutput
+	err := c.retryer.do(ctx, func() error {
+		var err error
+		output, err = c.srcClient.DeleteObjectsWithContext(ctx, input)
+		return err
+	})
+
+	var failed []CopyError
+	if err != nil {
+		for _, k := range keys {
+			failed = append(failed, CopyError{Key: k, Err: fmt.Errorf("%s/%s: copied but failed to delete the source: %v", bucket, k, err)})
+		}
+		return failed
+	}
+	for _, e := range output.Errors {
+		k := aws.StringValue(e.Key)
+		failed = append(failed, CopyError{Key: k, Err: fmt.Errorf("%s/%s: copied but failed to delete the source: %s: %s", bucket, k, aws.StringValue(e.Code), aws.StringValue(e.Message))})
+	}
+	return failed
+}
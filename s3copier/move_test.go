@@ -0,0 +1,120 @@
+package s3copier
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func TestMoveWithPrefixDeletesCopiedSources(t *testing.T) {
+	mock := newMockS3()
+	keys := mock.putKeys("src", "p/", 3, 1)
+	c := NewS3CopierWithClient(mock)
+
+	if err := c.MoveWithPrefix("src", "dest", "p/"); err != nil {
+		t.Fatalf("MoveWithPrefix failed: %v", err)
+	}
+	for _, k := range keys {
+		if mock.object("dest", k) == nil {
+			t.Errorf("dest/%s is not created", k)
+		}
+		if mock.object("src", k) != nil {
+			t.Errorf("src/%s is not deleted", k)
+		}
+	}
+}
+
+func TestMoveWithPrefixKeepsFailedSources(t *testing.T) {
+	mock := newMockS3()
+	mock.putKeys("src", "p/", 3, 1)
+	mock.hook = func(ctx aws.Context, op string, input interface{}) error {
+		if in, ok := input.(*s3.CopyObjectInput); ok && *in.Key == "p/1" {
+			return awsError("AccessDenied", 403)
+		}
+		return nil
+	}
+	c := NewS3CopierWithClient(mock, WithContinueOnError(true))
+
+	err := c.MoveWithPrefix("src", "dest", "p/")
+	if errs, ok := err.(CopyErrors); !ok || len(errs) != 1 || errs[0].Key != "p/1" {
+		t.Fatalf("MoveWithPrefix returned %v, want the error of p/1", err)
+	}
+	if mock.object("src", "p/1") == nil {
+		t.Error("src/p/1 is deleted although its copy failed")
+	}
+	for _, k := range []string{"p/0", "p/2"} {
+		if mock.object("src", k) != nil {
+			t.Errorf("src/%s is not deleted", k)
+		}
+	}
+}
+
+func TestMoveWithPrefixKeepsSourcesOnError(t *testing.T) {
+	mock := newMockS3()
+	mock.put("src", "p/0", 1)
+	mock.hook = func(ctx aws.Context, op string, input interface{}) error {
+		if op == OP_COPY_OBJECT {
+			return awsError("AccessDenied", 403)
+		}
+		return nil
+	}
+	c := NewS3CopierWithClient(mock)
+
+	if err := c.MoveWithPrefix("src", "dest", "p/"); err == nil {
+		t.Fatal("MoveWithPrefix succeeded, want the copy error")
+	}
+	if n := mock.count(OP_DELETE_OBJECTS); n != 0 {
+		t.Errorf("DeleteObjects is called %d times, want 0", n)
+	}
+	if mock.object("src", "p/0") == nil {
+		t.Error("src/p/0 is deleted although its copy failed")
+	}
+}
+
+func TestMoveWithPrefixDeletesInBatches(t *testing.T) {
+	mock := newMockS3()
+	keys := mock.putKeys("src", "p/", 2500, 1)
+	c := NewS3CopierWithClient(mock, WithRequestPayer(s3.RequestPayerRequester))
+
+	if err := c.MoveWithPrefix("src", "dest", "p/"); err != nil {
+		t.Fatalf("MoveWithPrefix failed: %v", err)
+	}
+	var sizes []int
+	for _, input := range mock.inputs(OP_DELETE_OBJECTS) {
+		in := input.(*s3.DeleteObjectsInput)
+		sizes = append(sizes, len(in.Delete.Objects))
+		if aws.StringValue(in.RequestPayer) != s3.RequestPayerRequester {
+			t.Errorf("DeleteObjects is sent with RequestPayer %q, want requester", aws.StringValue(in.RequestPayer))
+		}
+	}
+	if want := []int{1000, 1000, 500}; !reflect.DeepEqual(sizes, want) {
+		t.Errorf("DeleteObjects is sent with %v keys, want %v", sizes, want)
+	}
+	for _, k := range keys {
+		if mock.object("src", k) != nil {
+			t.Errorf("src/%s is not deleted", k)
+		}
+	}
+}
+
+func TestMoveWithPrefixReportsFailedDeletes(t *testing.T) {
+	mock := newMockS3()
+	mock.putKeys("src", "p/", 3, 1)
+	mock.failDeletes["p/1"] = true
+	c := NewS3CopierWithClient(mock)
+
+	err := c.MoveWithPrefix("src", "dest", "p/")
+	var copyErrors CopyErrors
+	if !errors.As(err, &copyErrors) || len(copyErrors) != 1 || copyErrors[0].Key != "p/1" {
+		t.Fatalf("MoveWithPrefix returned %v, want CopyErrors for p/1", err)
+	}
+	if mock.object("src", "p/1") == nil || mock.object("src", "p/0") != nil || mock.object("src", "p/2") != nil {
+		t.Error("only src/p/1 should be left in the source")
+	}
+	if n := mock.count(OP_COPY_OBJECT); n != 3 {
+		t.Errorf("CopyObject is called %d times, want the run to go on after the failed delete", n)
+	}
+}
@@ -0,0 +1,136 @@
+package s3copier
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Option configures an S3Copier created by NewS3Copier.
+type Option func(*S3Copier)
+
+// WithPartSize sets the part size of multipart copies (10MB by default).
+// It panics when size is smaller than 5MB, the minimum part size of S3.
+func WithPartSize(size int64) Option {
+	if size < FIVE_MB {
+		panic(fmt.Sprintf("s3copier: part size must be at least %d bytes, got %d", FIVE_MB, size))
+	}
+	return func(c *S3Copier) {
+		c.partSize = size
+	}
+}
+
+// WithAdaptivePartSize makes the part size of each object max(part size, ceil(object size / 10000))
+// rounded up to a 1MB boundary, where the part size is the one set by WithPartSize.
+// Without it the part size is also raised to fit in 10000 parts, but without rounding.
+func WithAdaptivePartSize() Option {
+	return func(c *S3Copier) {
+		c.adaptivePartSize = true
+	}
+}
+
+// WithMultipartThreshold sets the size above which objects are copied in multipart (5MB by default).
+// It panics when size is negative or larger than 5GB, the limit of CopyObject.
+func WithMultipartThreshold(size int64) Option {
+	if size < 0 || size > MAX_COPY_OBJECT_SIZE {
+		panic(fmt.Sprintf("s3copier: multipart threshold must be between 0 and %d bytes, got %d", int64(MAX_COPY_OBJECT_SIZE), size))
+	}
+	return func(c *S3Copier) {
+		c.multipartThreshold = size
+	}
+}
+
+// WithForceMultipart makes the copier copy every object in multipart regardless of
+// WithMultipartThreshold, except empty objects which have no byte range to copy.
+func WithForceMultipart(force bool) Option {
+	return func(c *S3Copier) {
+		c.forceMultipart = force
+	}
+}
+
+// WithWorkerCount sets how many objects CopyWithPrefix copies concurrently (50 by default).
+// It panics when n is less than 1.
+func WithWorkerCount(n int) Option {
+	if n < 1 {
+		panic(fmt.Sprintf("s3copier: worker count must be at least 1, got %d", n))
+	}
+	return func(c *S3Copier) {
+		c.workerCount = n
+	}
+}
+
+// WithChannelBuffer sets the capacity of the queues of listed keys and of copy results (20000 by default).
+// The listing waits for the workers when the key queue is full, so the capacity bounds the memory
+// used for very large prefixes; any capacity works for any number of objects.
+// It panics when n is negative.
+func WithChannelBuffer(n int) Option {
+	if n < 0 {
+		panic(fmt.Sprintf("s3copier: channel buffer must not be negative, got %d", n))
+	}
+	return func(c *S3Copier) {
+		c.channelBuffer = n
+	}
+}
+
+// WithPartConcurrency sets how many parts of one object are copied concurrently (10 by default).
+// It panics when n is less than 1.
+func WithPartConcurrency(n int) Option {
+	if n < 1 {
+		panic(fmt.Sprintf("s3copier: part concurrency must be at least 1, got %d", n))
+	}
+	return func(c *S3Copier) {
+		c.partConcurrency = n
+	}
+}
+
+// WithKeyMapper sets how CopyWithPrefix derives the destination key from each source key,
+// e.g. to replace a prefix. Keys are copied to the same key by default.
+func WithKeyMapper(fn func(srcKey string) (destKey string)) Option {
+	return func(c *S3Copier) {
+		c.keyMapper = fn
+	}
+}
+
+func identityKey(key string) string {
+	return key
+}
+
+// WithMaxObjects makes CopyWithPrefix copy at most n objects, e.g. to try a migration on a sample.
+// The listing stops once n objects are queued, or with WithObjectOrderPriority the n objects
+// with the highest priority are copied. Objects skipped by WithPreCopyExistenceIndex or filtered out
+// are not counted. 0 means no limit, the default. It panics when n is negative.
+func WithMaxObjects(n int) Option {
+	if n < 0 {
+		panic(fmt.Sprintf("s3copier: max objects must not be negative, got %d", n))
+	}
+	return func(c *S3Copier) {
+		c.maxObjects = n
+	}
+}
+
+// WithDelimiter sets the delimiter of the source listing, typically "/", so that CopyWithPrefix
+// copies only the objects directly under the prefix, not the ones under the "sub directories".
+func WithDelimiter(delimiter string) Option {
+	return func(c *S3Copier) {
+		c.delimiter = delimiter
+	}
+}
+
+// WithDryRun makes the copier do everything but writing to the destination: the source is listed
+// and headed, the filters and the skip options are applied, and the objects which would be copied
+// are counted in CopyResult as if they were. MoveWithPrefix doesn't delete the sources either.
+// The failure report of WithFailureReportKey is logged instead of being put to the bucket.
+func WithDryRun(dryRun bool) Option {
+	return func(c *S3Copier) {
+		c.dryRun = dryRun
+	}
+}
+
+// WithHeadPredicate decides from the HeadObject output of the source whether an object is copied.
+// Objects for which fn returns false are skipped. Unlike filters applied to the listing,
+// fn can look at attributes only HeadObject returns, such as Metadata and ContentType.
+func WithHeadPredicate(fn func(*s3.HeadObjectOutput) bool) Option {
+	return func(c *S3Copier) {
+		c.headPredicate = fn
+	}
+}
@@ -0,0 +1,160 @@
+package s3copier
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func TestNewS3CopierDefaults(t *testing.T) {
+	c := NewS3CopierWithClient(newMockS3())
+	if c.partSize != 10*ONE_MB {
+		t.Errorf("part size is %d, want %d", c.partSize, 10*ONE_MB)
+	}
+	if c.workerCount != WORKER_COUNT {
+		t.Errorf("worker count is %d, want %d", c.workerCount, WORKER_COUNT)
+	}
+	if c.partConcurrency != PART_CONCURRENCY {
+		t.Errorf("part concurrency is %d, want %d", c.partConcurrency, PART_CONCURRENCY)
+	}
+}
+
+func TestOptionsOverrideDefaults(t *testing.T) {
+	c := NewS3CopierWithClient(newMockS3(), WithPartSize(64*ONE_MB), WithWorkerCount(3), WithPartConcurrency(2))
+	if c.partSize != 64*ONE_MB {
+		t.Errorf("part size is %d, want %d", c.partSize, 64*ONE_MB)
+	}
+	if c.workerCount != 3 {
+		t.Errorf("worker count is %d, want 3", c.workerCount)
+	}
+	if c.partConcurrency != 2 {
+		t.Errorf("part concurrency is %d, want 2", c.partConcurrency)
+	}
+}
+
+func TestOptionsPanicOnInvalidValues(t *testing.T) {
+	tests := map[string]func(){
+		"part size below 5MB": func() { WithPartSize(FIVE_MB - 1) },
+		"no worker":           func() { WithWorkerCount(0) },
+		"no part concurrency": func() { WithPartConcurrency(0) },
+		"no drain timeout":    func() { WithDrainTimeout(0) },
+		"no request rate":     func() { WithRequestsPerSecond(0) },
+		"negative rate":       func() { WithRequestsPerSecond(-1) },
+	}
+	for name, fn := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("the option doesn't panic")
+				}
+			}()
+			fn()
+		})
+	}
+	// 5MB ちょうどは S3 の下限なので有効
+	WithPartSize(FIVE_MB)
+}
+
+func TestWithKeyMapper(t *testing.T) {
+	mock := newMockS3()
+	mock.put("src", "old/a.txt", 1)
+	mock.put("src", "old/b.bin", 20*ONE_MB)
+	c := NewS3CopierWithClient(mock, WithKeyMapper(func(srcKey string) string {
+		return "new/" + strings.TrimPrefix(srcKey, "old/")
+	}))
+
+	if err := c.CopyWithPrefix("src", "dest", "old/"); err != nil {
+		t.Fatalf("CopyWithPrefix failed: %v", err)
+	}
+	single := mock.inputs(OP_COPY_OBJECT)[0].(*s3.CopyObjectInput)
+	if *single.Key != "new/a.txt" || *single.CopySource != "src/old/a.txt" {
+		t.Errorf("CopyObject copies %s to %s, want src/old/a.txt to new/a.txt", *single.CopySource, *single.Key)
+	}
+	create := mock.inputs(OP_CREATE_MULTIPART_UPLOAD)[0].(*s3.CreateMultipartUploadInput)
+	if *create.Key != "new/b.bin" {
+		t.Errorf("CreateMultipartUpload is for %s, want new/b.bin", *create.Key)
+	}
+	for _, key := range []string{"new/a.txt", "new/b.bin"} {
+		if mock.object("dest", key) == nil {
+			t.Errorf("dest/%s is not created", key)
+		}
+	}
+	if mock.object("dest", "old/a.txt") != nil {
+		t.Error("dest/old/a.txt is created with the source key")
+	}
+}
+
+func TestWithAdaptivePartSize(t *testing.T) {
+	const gb = 1024 * ONE_MB
+	tests := []struct {
+		name       string
+		opts       []Option
+		objectSize int64
+		want       int64
+	}{
+		{name: "100MB", objectSize: 100 * ONE_MB, want: 10 * ONE_MB},
+		{name: "10GB", objectSize: 10 * gb, want: 10 * ONE_MB},
+		// ceil(1TB / 10000) = 109951163 を 1MB 単位に切り上げる
+		{name: "1TB", objectSize: 1024 * gb, want: 105 * ONE_MB},
+		{name: "unaligned part size", opts: []Option{WithPartSize(FIVE_MB + 1)}, objectSize: 100 * ONE_MB, want: 6 * ONE_MB},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewS3CopierWithClient(newMockS3(), append(tt.opts, WithAdaptivePartSize())...)
+			if got := c.partSizeFor(tt.objectSize); got != tt.want {
+				t.Errorf("partSizeFor(%d) = %d, want %d", tt.objectSize, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMultipartThreshold(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      []Option
+		size      int64
+		multipart bool
+	}{
+		{name: "default", size: 6 * ONE_MB, multipart: true},
+		{name: "forced", opts: []Option{WithForceMultipart(true)}, size: 6 * ONE_MB, multipart: true},
+		{name: "forced small", opts: []Option{WithForceMultipart(true)}, size: 1, multipart: true},
+		{name: "forced empty", opts: []Option{WithForceMultipart(true)}, size: 0, multipart: false},
+		{name: "raised threshold", opts: []Option{WithMultipartThreshold(10 * ONE_MB)}, size: 6 * ONE_MB, multipart: false},
+		{name: "above raised threshold", opts: []Option{WithMultipartThreshold(10 * ONE_MB)}, size: 10*ONE_MB + 1, multipart: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := newMockS3()
+			mock.put("src", "key", tt.size)
+			c := NewS3CopierWithClient(mock, tt.opts...)
+
+			if err := c.CopyTo(NewS3Object("src", "key"), NewS3Object("dest", "key")); err != nil {
+				t.Fatalf("CopyTo failed: %v", err)
+			}
+			creates, copies := mock.count(OP_CREATE_MULTIPART_UPLOAD), mock.count(OP_COPY_OBJECT)
+			if multipart := creates == 1 && copies == 0; multipart != tt.multipart || creates+copies != 1 {
+				t.Errorf("%d bytes are copied with %d CreateMultipartUpload and %d CopyObject, want multipart %v", tt.size, creates, copies, tt.multipart)
+			}
+		})
+	}
+}
+
+func TestWithMaxObjects(t *testing.T) {
+	mock := newMockS3()
+	mock.pageSize = 10
+	mock.putKeys("src", "p/", 100, 1)
+	c := NewS3CopierWithClient(mock, WithMaxObjects(10))
+
+	result, err := c.CopyWithPrefixResult("src", "dest", "p/")
+	if err != nil {
+		t.Fatalf("CopyWithPrefixResult failed: %v", err)
+	}
+	if result.ObjectsCopied != 10 || mock.count(OP_COPY_OBJECT) != 10 {
+		t.Errorf("%d objects are copied with %d CopyObject, want 10", result.ObjectsCopied, mock.count(OP_COPY_OBJECT))
+	}
+	// 10 個取れた時点で一覧を止める
+	if n := mock.count(OP_LIST_OBJECTS_V2); n != 1 {
+		t.Errorf("%d pages are listed, want 1", n)
+	}
+}
@@ -0,0 +1,27 @@
+package s3copier
+
+import "errors"
+
+// OverwritePolicy decides what happens when the destination key already exists.
+type OverwritePolicy int
+
+const (
+	// OverwriteAlways overwrites the destination object (default).
+	OverwriteAlways OverwritePolicy = iota
+	// OverwriteSkip leaves the destination object as is and counts the object as skipped.
+	OverwriteSkip
+	// OverwriteFail fails the copy with ErrDestinationExists.
+	OverwriteFail
+)
+
+// ErrDestinationExists is returned under OverwriteFail when the destination key already exists.
+var ErrDestinationExists = errors.New("s3copier: destination already exists")
+
+// WithOverwritePolicy sets what to do with existing destination keys, checked by a HeadObject
+// on the destination before each copy. It is checked after WithSkipExisting, so unchanged objects
+// are still skipped under OverwriteFail.
+func WithOverwritePolicy(policy OverwritePolicy) Option {
+	return func(c *S3Copier) {
+		c.overwritePolicy = policy
+	}
+}
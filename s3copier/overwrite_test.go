@@ -0,0 +1,51 @@
+package s3copier
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestOverwritePolicy(t *testing.T) {
+	tests := []struct {
+		policy  OverwritePolicy
+		copied  bool
+		skipped bool
+		err     error
+	}{
+		{policy: OverwriteAlways, copied: true},
+		{policy: OverwriteSkip, skipped: true},
+		{policy: OverwriteFail, err: ErrDestinationExists},
+	}
+	for _, tt := range tests {
+		mock := newMockS3()
+		mock.put("src", "p/key", 100)
+		mock.put("dest", "p/key", 1)
+		c := NewS3CopierWithClient(mock, WithOverwritePolicy(tt.policy))
+
+		result, err := c.CopyWithPrefixResult("src", "dest", "p/")
+		if !errors.Is(err, tt.err) {
+			t.Errorf("policy %d: CopyWithPrefixResult returned %v, want %v", tt.policy, err, tt.err)
+		}
+		if got := mock.object("dest", "p/key").size == 100; got != tt.copied {
+			t.Errorf("policy %d: destination overwritten = %v, want %v", tt.policy, got, tt.copied)
+		}
+		if got := result.Skipped == 1; got != tt.skipped {
+			t.Errorf("policy %d: %d objects skipped, want skipped = %v", tt.policy, result.Skipped, tt.skipped)
+		}
+	}
+}
+
+func TestOverwritePolicyCopiesMissingDestination(t *testing.T) {
+	for _, policy := range []OverwritePolicy{OverwriteSkip, OverwriteFail} {
+		mock := newMockS3()
+		mock.put("src", "key", 100)
+		c := NewS3CopierWithClient(mock, WithOverwritePolicy(policy))
+
+		if err := c.CopyTo(NewS3Object("src", "key"), NewS3Object("dest", "key")); err != nil {
+			t.Errorf("policy %d: CopyTo failed: %v", policy, err)
+		}
+		if mock.object("dest", "key") == nil {
+			t.Errorf("policy %d: destination is not created", policy)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package s3copier
+
+import "sort"
+
+// WithObjectOrderPriority makes CopyWithPrefix copy keys with a higher fn(key) first,
+// e.g. to copy `config/` and `index/` before bulk data. Keys of the same priority keep the listing order.
+//
+// The whole listing is buffered in memory and sorted before any copy starts, so copying does not
+// overlap with listing, and a run of millions of objects holds every key in memory at once.
+func WithObjectOrderPriority(fn func(key string) int) Option {
+	return func(c *S3Copier) {
+		c.priority = fn
+	}
+}
+
+func (c *S3Copier) sortByPriority(keys []string) []string {
+	priorities := make(map[string]int, len(keys))
+	for _, k := range keys {
+		priorities[k] = c.priority(k)
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		return priorities[keys[i]] > priorities[keys[j]]
+	})
+	return keys
+}
@@ -0,0 +1,50 @@
+package s3copier
+
+// ProgressEvent is passed to the WithProgress callback for each object CopyWithPrefix has processed.
+type ProgressEvent struct {
+	Key       string
+	Size      int64
+	Multipart bool
+	// Skipped is true when the object was not copied, e.g. by WithHeadPredicate
+	Skipped bool
+	// Err is the error the object failed with under WithContinueOnError
+	Err error
+	// Completed is the number of objects processed so far in the run, including this one
+	Completed int
+}
+
+// WithProgress sets a callback invoked by CopyWithPrefix as each object completes.
+// It is never called concurrently, so fn needs no locking of its own, but a slow fn slows down the run.
+func WithProgress(fn func(ev ProgressEvent)) Option {
+	return func(c *S3Copier) {
+		c.progress = fn
+	}
+}
+
+// WithPartProgress sets a callback invoked as each part of a multipart copy completes,
+// with the source key, the part number, the number of parts of the object and the size of the part.
+// Parts are copied concurrently, so partNum is not necessarily increasing, but fn is never called
+// concurrently, even for parts of different objects.
+func WithPartProgress(fn func(key string, partNum, totalParts int64, bytes int64)) Option {
+	return func(c *S3Copier) {
+		c.partProgress = fn
+	}
+}
+
+func (c *S3Copier) reportProgress(ev ProgressEvent) {
+	if c.progress == nil {
+		return
+	}
+	c.progressMu.Lock()
+	defer c.progressMu.Unlock()
+	c.progress(ev)
+}
+
+func (c *S3Copier) reportPartProgress(key string, partNum, totalParts int64, bytes int64) {
+	if c.partProgress == nil {
+		return
+	}
+	c.partProgressMu.Lock()
+	defer c.partProgressMu.Unlock()
+	c.partProgress(key, partNum, totalParts, bytes)
+}
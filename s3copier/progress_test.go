@@ -0,0 +1,37 @@
+package s3copier
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestWithPartProgress(t *testing.T) {
+	for _, concurrency := range []int{1, 10} {
+		mock := newMockS3()
+		mock.put("src", "key", 45*ONE_MB)
+		var parts []int
+		var total int64
+		c := NewS3CopierWithClient(mock, WithPartConcurrency(concurrency), WithPartProgress(func(key string, partNum, totalParts int64, bytes int64) {
+			if key != "key" || totalParts != 5 {
+				t.Errorf("part progress is reported for %s with %d parts, want key with 5 parts", key, totalParts)
+			}
+			parts = append(parts, int(partNum))
+			total += bytes
+		}))
+
+		if err := c.CopyTo(NewS3Object("src", "key"), NewS3Object("dest", "key")); err != nil {
+			t.Fatalf("CopyTo failed: %v", err)
+		}
+		// 並行にコピーすると順番は決まらない
+		if concurrency > 1 {
+			sort.Ints(parts)
+		}
+		if want := []int{1, 2, 3, 4, 5}; !reflect.DeepEqual(parts, want) {
+			t.Errorf("with part concurrency %d, progress is reported for parts %v, want %v", concurrency, parts, want)
+		}
+		if total != 45*ONE_MB {
+			t.Errorf("with part concurrency %d, progress is reported for %d bytes, want %d", concurrency, total, 45*ONE_MB)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package s3copier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// WithFailureReportKey makes CopyWithPrefix put a JSON report of the objects that failed to copy
+// (and of the skipped ones with the reasons) to bucket/key at the end of every run,
+// so that migrations can be reconciled without relying on logs.
+func WithFailureReportKey(bucket, key string) Option {
+	return func(c *S3Copier) {
+		c.failureReport = &S3Object{bucket: bucket, key: key}
+	}
+}
+
+type failureReport struct {
+	SourceBucket      string          `json:"source_bucket"`
+	DestinationBucket string          `json:"destination_bucket"`
+	Prefix            string          `json:"prefix"`
+	FinishedAt        time.Time       `json:"finished_at"`
+	Failures          []failureRecord `json:"failures"`
+	Skipped           []skipRecord    `json:"skipped"`
+}
+
+type failureRecord struct {
+	Key   string `json:"key"`
+	Error string `json:"error"`
+}
+
+type skipRecord struct {
+	Key    string `json:"key"`
+	Reason string `json:"reason"`
+}
+
+func (c *S3Copier) writeFailureReport(ctx context.Context, srcBucket, destBucket, prefix string, failures, skipped []jobResult) error {
+	report := failureReport{
+		SourceBucket:      srcBucket,
+		DestinationBucket: destBucket,
+		Prefix:            prefix,
+		FinishedAt:        time.Now(),
+		Failures:          []failureRecord{},
+		Skipped:           []skipRecord{},
+	}
+	for _, f := range failures {
+		report.Failures = append(report.Failures, failureRecord{Key: f.key, Error: f.err.Error()})
+	}
+	for _, s := range skipped {
+		report.Skipped = append(report.Skipped, skipRecord{Key: s.key, Reason: s.skipReason})
+	}
+	body, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if c.dryRun {
+		// dry run では destination に何も書き込まない
+		c.logger.Infof("dry run: failure report %s is not written: %s", c.failureReport.bucketKeyPath(), body)
+		return nil
+	}
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(c.failureReport.bucket),
+		Key:         aws.String(c.failureReport.key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/json"),
+	}
+	c.decorate(OP_PUT_OBJECT, input)
+	return c.retryer.do(ctx, func() error {
+		_, err := c.destClient.PutObjectWithContext(ctx, input)
+		return err
+	})
+}
@@ -0,0 +1,42 @@
+package s3copier
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// WithRequestPayer sets RequestPayer, typically s3.RequestPayerRequester, on every request
+// that accepts it, so that objects can be copied from (and to) requester-pays buckets.
+func WithRequestPayer(payer string) Option {
+	return WithRequestDecorator(func(op string, input interface{}) {
+		p := aws.String(payer)
+		switch in := input.(type) {
+		case *s3.ListObjectsV2Input:
+			in.RequestPayer = p
+		case *s3.HeadObjectInput:
+			in.RequestPayer = p
+		case *s3.CopyObjectInput:
+			in.RequestPayer = p
+		case *s3.CreateMultipartUploadInput:
+			in.RequestPayer = p
+		case *s3.UploadPartCopyInput:
+			in.RequestPayer = p
+		case *s3.CompleteMultipartUploadInput:
+			in.RequestPayer = p
+		case *s3.AbortMultipartUploadInput:
+			in.RequestPayer = p
+		case *s3.GetObjectInput:
+			in.RequestPayer = p
+		case *s3.ListPartsInput:
+			in.RequestPayer = p
+		case *s3.GetObjectAclInput:
+			in.RequestPayer = p
+		case *s3.PutObjectInput:
+			in.RequestPayer = p
+		case *s3.DeleteObjectInput:
+			in.RequestPayer = p
+		case *s3.DeleteObjectsInput:
+			in.RequestPayer = p
+		}
+	})
+}
@@ -0,0 +1,33 @@
+package s3copier
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func TestWithRequestPayer(t *testing.T) {
+	mock := newMockS3()
+	mock.put("src", "p/single", 1)
+	mock.put("src", "p/multi", 20*ONE_MB)
+	c := NewS3CopierWithClient(mock, WithRequestPayer(s3.RequestPayerRequester))
+
+	if err := c.CopyWithPrefix("src", "dest", "p/"); err != nil {
+		t.Fatalf("CopyWithPrefix failed: %v", err)
+	}
+	ops := []string{OP_LIST_OBJECTS_V2, OP_HEAD_OBJECT, OP_COPY_OBJECT, OP_CREATE_MULTIPART_UPLOAD, OP_UPLOAD_PART_COPY, OP_COMPLETE_MULTIPART_UPLOAD}
+	for _, op := range ops {
+		inputs := mock.inputs(op)
+		if len(inputs) == 0 {
+			t.Errorf("%s is not sent", op)
+		}
+		for _, input := range inputs {
+			payer := reflect.ValueOf(input).Elem().FieldByName("RequestPayer").Interface().(*string)
+			if payer == nil || *payer != s3.RequestPayerRequester {
+				t.Errorf("%s is sent without RequestPayer requester", op)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package s3copier
+
+import "time"
+
+// CopyResult summarizes a CopyWithPrefix run.
+type CopyResult struct {
+	ObjectsCopied   int
+	BytesCopied     int64
+	SinglePartCount int
+	MultiPartCount  int
+	Skipped         int
+	Duration        time.Duration
+	// SkippedObjects has the keys counted in Skipped with the reasons
+	SkippedObjects []SkippedObject
+	// Missing has the keys skipped under WithSkipMissing, which are also counted in Skipped
+	Missing []string
+	// Errors has the objects which failed under WithContinueOnError
+	Errors []CopyError
+}
+
+// SkippedObject is an object which was not copied, e.g. by WithSkippableSourceErrors.
+type SkippedObject struct {
+	Key    string
+	Reason string
+}
+
+func (r *CopyResult) add(copied objectCopy) {
+	r.ObjectsCopied++
+	r.BytesCopied += copied.size
+	if copied.multipart {
+		r.MultiPartCount++
+	} else {
+		r.SinglePartCount++
+	}
+}
@@ -0,0 +1,116 @@
+package s3copier
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// WithResume makes multipart copies continue an upload left in progress for the destination key,
+// e.g. by a killed process, copying only the parts which are missing.
+// Uploads initiated before the source was last modified are aborted instead, as their parts may be stale,
+// and so are uploads whose parts don't have the byte ranges of the current part size, e.g. after
+// WithPartSize was changed. Uploads of failed or cancelled copies are still aborted, so only
+// interrupted runs can be resumed.
+func WithResume(resume bool) Option {
+	return func(c *S3Copier) {
+		c.resume = resume
+	}
+}
+
+type uploadedPart struct {
+	etag string
+	size int64
+}
+
+// findResumableUpload returns the newest upload in progress for dest with its parts,
+// or a nil uploadId when there is nothing to resume. The other uploads for dest are aborted.
+// The parts must have been copied with partSize, otherwise the upload is aborted too.
+func (c *S3Copier) findResumableUpload(ctx context.Context, dest *S3Object, srcHead *s3.HeadObjectOutput, partSize int64) (*string, map[int64]uploadedPart, error) {
+	input := &s3.ListMultipartUploadsInput{
+		Bucket: aws.String(dest.bucket),
+		Prefix: aws.String(dest.key),
+	}
+	c.decorate(OP_LIST_MULTIPART_UPLOADS, input)
+	var uploads []*s3.MultipartUpload
+	err := c.retryer.do(ctx, func() error {
+		// retry するときは最初のページからやり直す
+		uploads = nil
+		return c.destClient.ListMultipartUploadsPagesWithContext(ctx, input, func(page *s3.ListMultipartUploadsOutput, lastPage bool) bool {
+			uploads = append(uploads, page.Uploads...)
+			return true
+		})
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var resumable *s3.MultipartUpload
+	for _, upload := range uploads {
+		// Prefix なので別の key の upload も含まれる
+		if aws.StringValue(upload.Key) != dest.key {
+			continue
+		}
+		stale := aws.TimeValue(upload.Initiated).Before(aws.TimeValue(srcHead.LastModified))
+		if !stale && (resumable == nil || aws.TimeValue(upload.Initiated).After(aws.TimeValue(resumable.Initiated))) {
+			if resumable != nil {
+				c.abortMultipartUpload(dest, resumable.UploadId)
+			}
+			resumable = upload
+			continue
+		}
+		c.abortMultipartUpload(dest, upload.UploadId)
+	}
+	if resumable == nil {
+		return nil, nil, nil
+	}
+
+	parts := map[int64]uploadedPart{}
+	partsInput := &s3.ListPartsInput{
+		Bucket:   aws.String(dest.bucket),
+		Key:      aws.String(dest.key),
+		UploadId: resumable.UploadId,
+	}
+	c.decorate(OP_LIST_PARTS, partsInput)
+	err = c.retryer.do(ctx, func() error {
+		return c.destClient.ListPartsPagesWithContext(ctx, partsInput, func(page *s3.ListPartsOutput, lastPage bool) bool {
+			for _, part := range page.Parts {
+				parts[aws.Int64Value(part.PartNumber)] = uploadedPart{
+					etag: strings.Trim(aws.StringValue(part.ETag), "\""),
+					size: aws.Int64Value(part.Size),
+				}
+			}
+			return true
+		})
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if partNum, ok := checkPartSizes(parts, aws.Int64Value(srcHead.ContentLength), partSize); !ok {
+		// 別の offset の part を繋ぐと object が壊れるので最初からやり直す
+		c.logger.Infof("multipart upload %s of %s is not resumed: part %d was not copied with %d byte parts", *resumable.UploadId, dest.bucketKeyPath(), partNum, partSize)
+		c.abortMultipartUpload(dest, resumable.UploadId)
+		return nil, nil, nil
+	}
+	c.logger.Infof("resuming multipart upload %s of %s with %d parts", *resumable.UploadId, dest.bucketKeyPath(), len(parts))
+	return resumable.UploadId, parts, nil
+}
+
+// checkPartSizes checks that each uploaded part has the size of the part with the same number
+// when objectSize is split into parts of partSize, i.e. that the upload was copied with partSize.
+// It returns false with the number of a part which doesn't.
+func checkPartSizes(parts map[int64]uploadedPart, objectSize, partSize int64) (int64, bool) {
+	partsSize := (objectSize + partSize - 1) / partSize
+	for partNum, part := range parts {
+		want := partSize
+		if partNum == partsSize {
+			want = objectSize - (partsSize-1)*partSize
+		}
+		if partNum < 1 || partNum > partsSize || part.size != want {
+			return partNum, false
+		}
+	}
+	return 0, true
+}
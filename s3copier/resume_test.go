@@ -0,0 +1,168 @@
+package s3copier
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// interruptUpload leaves an upload of bucket/key in progress with the given parts of partSize bytes copied,
+// as a killed process would.
+func (m *mockS3) interruptUpload(bucket, key string, initiated time.Time, partSize int64, partNums ...int64) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextUploadId++
+	uploadId := fmt.Sprintf("interrupted-%05d", m.nextUploadId)
+	upload := &mockUpload{
+		bucket:    bucket,
+		key:       key,
+		initiated: initiated,
+		input:     &s3.CreateMultipartUploadInput{Bucket: aws.String(bucket), Key: aws.String(key)},
+		parts:     map[int64]*s3.Part{},
+	}
+	for _, n := range partNums {
+		upload.parts[n] = &s3.Part{
+			PartNumber: aws.Int64(n),
+			ETag:       aws.String(mockETag(fmt.Sprintf("%s/%d", uploadId, n))),
+			Size:       aws.Int64(partSize),
+		}
+	}
+	m.uploads[uploadId] = upload
+	return uploadId
+}
+
+func TestResumeCopiesOnlyMissingParts(t *testing.T) {
+	mock := newMockS3()
+	mock.put("src", "key", 50*ONE_MB)
+	uploadId := mock.interruptUpload("dest", "key", time.Now(), 10*ONE_MB, 1, 2)
+	c := NewS3CopierWithClient(mock, WithResume(true), WithRequestPayer(s3.RequestPayerRequester))
+
+	if err := c.CopyTo(NewS3Object("src", "key"), NewS3Object("dest", "key")); err != nil {
+		t.Fatalf("CopyTo failed: %v", err)
+	}
+	if n := mock.count(OP_CREATE_MULTIPART_UPLOAD); n != 0 {
+		t.Errorf("CreateMultipartUpload is called %d times, want the upload to be resumed", n)
+	}
+	for _, input := range mock.inputs(OP_UPLOAD_PART_COPY) {
+		in := input.(*s3.UploadPartCopyInput)
+		if *in.UploadId != uploadId || *in.PartNumber <= 2 {
+			t.Errorf("part %d is copied to %s, want only parts 3 to 5 to %s", *in.PartNumber, *in.UploadId, uploadId)
+		}
+	}
+	if n := mock.count(OP_UPLOAD_PART_COPY); n != 3 {
+		t.Errorf("UploadPartCopy is called %d times, want 3", n)
+	}
+	if dest := mock.object("dest", "key"); dest == nil || dest.size != 50*ONE_MB {
+		t.Errorf("destination is %+v, want %d bytes", dest, 50*ONE_MB)
+	}
+	list := mock.inputs(OP_LIST_PARTS)[0].(*s3.ListPartsInput)
+	if aws.StringValue(list.RequestPayer) != s3.RequestPayerRequester {
+		t.Errorf("ListParts is sent with RequestPayer %q, want requester", aws.StringValue(list.RequestPayer))
+	}
+}
+
+func TestResumeAbortsStaleUpload(t *testing.T) {
+	mock := newMockS3()
+	// source の LastModified より前に始まった upload
+	uploadId := mock.interruptUpload("dest", "key", time.Now().Add(-2*time.Hour), 10*ONE_MB, 1, 2)
+	mock.put("src", "key", 50*ONE_MB)
+	c := NewS3CopierWithClient(mock, WithResume(true))
+
+	if err := c.CopyTo(NewS3Object("src", "key"), NewS3Object("dest", "key")); err != nil {
+		t.Fatalf("CopyTo failed: %v", err)
+	}
+	aborts := mock.inputs(OP_ABORT_MULTIPART_UPLOAD)
+	if len(aborts) != 1 || *aborts[0].(*s3.AbortMultipartUploadInput).UploadId != uploadId {
+		t.Errorf("%d uploads are aborted, want only the stale %s", len(aborts), uploadId)
+	}
+	if n := mock.count(OP_CREATE_MULTIPART_UPLOAD); n != 1 {
+		t.Errorf("CreateMultipartUpload is called %d times, want 1", n)
+	}
+	if n := mock.count(OP_UPLOAD_PART_COPY); n != 5 {
+		t.Errorf("UploadPartCopy is called %d times, want 5", n)
+	}
+}
+
+func TestResumeAbortsUploadWithOtherPartSize(t *testing.T) {
+	mock := newMockS3()
+	mock.put("src", "key", 30*ONE_MB)
+	// 前回は 10MB の part でコピーしていた。新しい part 2 は 20-30MB なので前回の part 2 は使えない
+	uploadId := mock.interruptUpload("dest", "key", time.Now(), 10*ONE_MB, 1, 2)
+	c := NewS3CopierWithClient(mock, WithResume(true), WithPartSize(20*ONE_MB))
+
+	if err := c.CopyTo(NewS3Object("src", "key"), NewS3Object("dest", "key")); err != nil {
+		t.Fatalf("CopyTo failed: %v", err)
+	}
+	aborts := mock.inputs(OP_ABORT_MULTIPART_UPLOAD)
+	if len(aborts) != 1 || *aborts[0].(*s3.AbortMultipartUploadInput).UploadId != uploadId {
+		t.Errorf("%d uploads are aborted, want %s whose parts have another size", len(aborts), uploadId)
+	}
+	if n := mock.count(OP_CREATE_MULTIPART_UPLOAD); n != 1 {
+		t.Errorf("CreateMultipartUpload is called %d times, want 1", n)
+	}
+	if n := mock.count(OP_UPLOAD_PART_COPY); n != 2 {
+		t.Errorf("UploadPartCopy is called %d times, want 2", n)
+	}
+	if dest := mock.object("dest", "key"); dest == nil || dest.size != 30*ONE_MB {
+		t.Errorf("destination is %+v, want %d bytes", dest, 30*ONE_MB)
+	}
+}
+
+func TestCheckPartSizes(t *testing.T) {
+	const objectSize = 25 * ONE_MB
+	tests := []struct {
+		name  string
+		parts map[int64]int64
+		ok    bool
+	}{
+		{name: "none", ok: true},
+		{name: "all", parts: map[int64]int64{1: 10 * ONE_MB, 2: 10 * ONE_MB, 3: 5 * ONE_MB}, ok: true},
+		{name: "with a gap", parts: map[int64]int64{1: 10 * ONE_MB, 3: 5 * ONE_MB}, ok: true},
+		{name: "smaller part", parts: map[int64]int64{1: 5 * ONE_MB}},
+		{name: "larger last part", parts: map[int64]int64{3: 10 * ONE_MB}},
+		{name: "beyond the last part", parts: map[int64]int64{1: 10 * ONE_MB, 4: 5 * ONE_MB}},
+	}
+	for _, tt := range tests {
+		parts := map[int64]uploadedPart{}
+		for n, size := range tt.parts {
+			parts[n] = uploadedPart{etag: "etag", size: size}
+		}
+		if _, ok := checkPartSizes(parts, objectSize, 10*ONE_MB); ok != tt.ok {
+			t.Errorf("%s: checkPartSizes = %v, want %v", tt.name, ok, tt.ok)
+		}
+	}
+}
+
+func TestResumeListsEveryUploadPage(t *testing.T) {
+	mock := newMockS3()
+	mock.pageSize = 10
+	var stale []string
+	for i := 0; i < 25; i++ {
+		stale = append(stale, mock.interruptUpload("dest", "key", time.Now().Add(-2*time.Hour), 10*ONE_MB, 1))
+	}
+	// upload id の順で最後のページに来る
+	uploadId := mock.interruptUpload("dest", "key", time.Now(), 10*ONE_MB, 1, 2)
+	mock.put("src", "key", 50*ONE_MB)
+	c := NewS3CopierWithClient(mock, WithResume(true))
+
+	if err := c.CopyTo(NewS3Object("src", "key"), NewS3Object("dest", "key")); err != nil {
+		t.Fatalf("CopyTo failed: %v", err)
+	}
+	if n := mock.count(OP_LIST_MULTIPART_UPLOADS); n != 3 {
+		t.Errorf("%d pages of uploads are listed, want 3", n)
+	}
+	if n := mock.count(OP_ABORT_MULTIPART_UPLOAD); n != len(stale) {
+		t.Errorf("%d uploads are aborted, want the %d stale ones", n, len(stale))
+	}
+	for _, input := range mock.inputs(OP_UPLOAD_PART_COPY) {
+		if id := *input.(*s3.UploadPartCopyInput).UploadId; id != uploadId {
+			t.Errorf("part is copied to %s, want %s to be resumed", id, uploadId)
+		}
+	}
+	if n := mock.count(OP_UPLOAD_PART_COPY); n != 3 {
+		t.Errorf("UploadPartCopy is called %d times, want 3", n)
+	}
+}
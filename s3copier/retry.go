@@ -0,0 +1,152 @@
+package s3copier
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"golang.org/x/time/rate"
+)
+
+const (
+	DEFAULT_RETRY_BASE = 100 * time.Millisecond
+	DEFAULT_RETRY_CAP  = 20 * time.Second
+)
+
+// retryer retries S3 calls with full-jitter exponential backoff:
+// sleep = random(0, min(cap, base*2^attempt)).
+// https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/
+type retryer struct {
+	maxRetries int
+	base       time.Duration
+	cap        time.Duration
+	// nil なら制限しない
+	limiter *rate.Limiter
+	metrics MetricsRecorder
+
+	mu    sync.Mutex
+	rand  *rand.Rand
+	sleep func(context.Context, time.Duration) error
+}
+
+func newRetryer() *retryer {
+	return &retryer{
+		base:    DEFAULT_RETRY_BASE,
+		cap:     DEFAULT_RETRY_CAP,
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		sleep:   sleepContext,
+		metrics: nopMetrics{},
+	}
+}
+
+// WithMaxRetries sets how many times a failed S3 call is retried (0 disables retrying).
+// This is on top of the retries the SDK itself does. Only throttling (e.g. SlowDown) and
+// transient errors (5xx, timeouts) are retried, other errors fail immediately.
+func WithMaxRetries(n int) Option {
+	return func(c *S3Copier) {
+		c.retryer.maxRetries = n
+	}
+}
+
+// WithBackoff sets the base and the cap of the full-jitter backoff between retries.
+func WithBackoff(base, cap time.Duration) Option {
+	return func(c *S3Copier) {
+		c.retryer.base = base
+		c.retryer.cap = cap
+	}
+}
+
+// WithRequestsPerSecond limits the rate of the S3 requests sent by all the workers in total,
+// retries included. Bursts of up to one second worth of requests are allowed.
+// It panics when rps is not positive.
+func WithRequestsPerSecond(rps float64) Option {
+	if rps <= 0 {
+		panic(fmt.Sprintf("s3copier: requests per second must be positive, got %v", rps))
+	}
+	burst := int(rps)
+	if burst < 1 {
+		burst = 1
+	}
+	return func(c *S3Copier) {
+		c.retryer.limiter = rate.NewLimiter(rate.Limit(rps), burst)
+	}
+}
+
+// delay returns the sleep before the retry following the given attempt (0 origin).
+func (r *retryer) delay(attempt int) time.Duration {
+	d := r.cap
+	// base*2^attempt がオーバーフローしないように cap を超えた時点で打ち切る
+	if attempt < 63 && r.base > 0 && r.base <= r.cap>>uint(attempt) {
+		d = r.base << uint(attempt)
+	}
+	if d <= 0 {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return time.Duration(r.rand.Int63n(int64(d)))
+}
+
+func (r *retryer) do(ctx context.Context, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		if err := r.wait(ctx); err != nil {
+			return err
+		}
+		err := fn()
+		if err == nil || attempt >= r.maxRetries || !isRetryable(err) {
+			return err
+		}
+		r.metrics.IncRetries()
+		if err := r.sleep(ctx, r.delay(attempt)); err != nil {
+			return err
+		}
+	}
+}
+
+// wait blocks until a request is allowed by WithRequestsPerSecond.
+func (r *retryer) wait(ctx context.Context) error {
+	if r.limiter == nil {
+		return nil
+	}
+	return r.limiter.Wait(ctx)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// S3 specific error codes which are not in the throttle/retryable codes of the SDK.
+var retryableS3Codes = map[string]bool{
+	"SlowDown":           true,
+	"InternalError":      true,
+	"ServiceUnavailable": true,
+	"RequestTimeout":     true,
+}
+
+func isRetryable(err error) bool {
+	if request.IsErrorThrottle(err) || request.IsErrorRetryable(err) {
+		return true
+	}
+	if aerr, ok := err.(awserr.Error); ok && retryableS3Codes[aerr.Code()] {
+		return true
+	}
+	// HeadObject のエラーはボディがないのでステータスで判定する
+	switch statusCode(err) {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,160 @@
+package s3copier
+
+import (
+	"context"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// fakeSleep records the sleeps of r instead of sleeping.
+func fakeSleep(r *retryer) *[]time.Duration {
+	var sleeps []time.Duration
+	r.sleep = func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return nil
+	}
+	return &sleeps
+}
+
+func TestRetryFullJitterBackoff(t *testing.T) {
+	r := newRetryer()
+	r.rand = rand.New(rand.NewSource(1))
+	r.maxRetries = 12
+	r.base = 100 * time.Millisecond
+	r.cap = 5 * time.Second
+	sleeps := fakeSleep(r)
+
+	attempts := 0
+	err := r.do(context.Background(), func() error {
+		attempts++
+		return awsError("SlowDown", 503)
+	})
+	if err == nil {
+		t.Fatal("do succeeded, want the SlowDown error")
+	}
+	if attempts != 13 || len(*sleeps) != 12 {
+		t.Fatalf("%d attempts with %d sleeps, want 13 attempts with 12 sleeps", attempts, len(*sleeps))
+	}
+	var total time.Duration
+	for attempt, d := range *sleeps {
+		bound := r.base << uint(attempt)
+		if bound > r.cap {
+			bound = r.cap
+		}
+		if d < 0 || d >= bound {
+			t.Errorf("sleep before retry %d is %v, want within [0, %v)", attempt+1, d, bound)
+		}
+		total += d
+	}
+	if total == 0 {
+		t.Error("every sleep is 0, the delays are not random")
+	}
+}
+
+func TestRetryDelayDoesNotOverflow(t *testing.T) {
+	r := newRetryer()
+	r.rand = rand.New(rand.NewSource(1))
+	for _, attempt := range []int{62, 63, 64, 1000} {
+		if d := r.delay(attempt); d < 0 || d >= r.cap {
+			t.Errorf("delay(%d) = %v, want within [0, %v)", attempt, d, r.cap)
+		}
+	}
+}
+
+func TestRetryStopsOnNonRetryableError(t *testing.T) {
+	r := newRetryer()
+	r.maxRetries = 5
+	sleeps := fakeSleep(r)
+
+	attempts := 0
+	r.do(context.Background(), func() error {
+		attempts++
+		return awsError("AccessDenied", 403)
+	})
+	if attempts != 1 || len(*sleeps) != 0 {
+		t.Errorf("%d attempts with %d sleeps, want 1 attempt without sleep", attempts, len(*sleeps))
+	}
+}
+
+func TestCopyToRetriesSlowDown(t *testing.T) {
+	tests := []struct {
+		op   string
+		size int64
+	}{
+		{OP_HEAD_OBJECT, 1},
+		{OP_COPY_OBJECT, 1},
+		{OP_CREATE_MULTIPART_UPLOAD, 20 * ONE_MB},
+		{OP_UPLOAD_PART_COPY, 20 * ONE_MB},
+		{OP_COMPLETE_MULTIPART_UPLOAD, 20 * ONE_MB},
+	}
+	for _, tt := range tests {
+		t.Run(tt.op, func(t *testing.T) {
+			mock := newMockS3()
+			mock.put("src", "key", tt.size)
+			var failures int32
+			mock.hook = func(ctx aws.Context, op string, input interface{}) error {
+				if op == tt.op && atomic.AddInt32(&failures, 1) <= 2 {
+					return awsError("SlowDown", 503)
+				}
+				return nil
+			}
+			c := NewS3CopierWithClient(mock, WithMaxRetries(3), WithPartConcurrency(1))
+			sleeps := fakeSleep(c.retryer)
+
+			if err := c.CopyTo(NewS3Object("src", "key"), NewS3Object("dest", "key")); err != nil {
+				t.Fatalf("CopyTo failed: %v", err)
+			}
+			if len(*sleeps) != 2 {
+				t.Errorf("%d retries, want 2", len(*sleeps))
+			}
+			if dest := mock.object("dest", "key"); dest == nil || dest.size != tt.size {
+				t.Errorf("destination is %+v, want %d bytes", dest, tt.size)
+			}
+		})
+	}
+}
+
+func TestCopyToFailsAfterMaxRetries(t *testing.T) {
+	mock := newMockS3()
+	mock.put("src", "key", 1)
+	mock.hook = func(ctx aws.Context, op string, input interface{}) error {
+		if op == OP_COPY_OBJECT {
+			return awsError("SlowDown", 503)
+		}
+		return nil
+	}
+	c := NewS3CopierWithClient(mock, WithMaxRetries(2))
+	fakeSleep(c.retryer)
+
+	if err := c.CopyTo(NewS3Object("src", "key"), NewS3Object("dest", "key")); err == nil {
+		t.Fatal("CopyTo succeeded, want the SlowDown error")
+	}
+	if n := mock.count(OP_COPY_OBJECT); n != 3 {
+		t.Errorf("CopyObject is called %d times, want 3", n)
+	}
+}
+
+func TestRequestsPerSecondSlowsDownCopies(t *testing.T) {
+	copyAll := func(opts ...Option) time.Duration {
+		mock := newMockS3()
+		// list 1回と head と copy が 35 object 分で 71 リクエスト
+		mock.putKeys("src", "p/", 35, 1)
+		c := NewS3CopierWithClient(mock, opts...)
+		started := time.Now()
+		if err := c.CopyWithPrefix("src", "dest", "p/"); err != nil {
+			t.Fatalf("CopyWithPrefix failed: %v", err)
+		}
+		return time.Since(started)
+	}
+
+	unlimited := copyAll()
+	// burst の 50 を超えた 21 リクエストは 50rps で 0.4秒ほどかかる
+	limited := copyAll(WithRequestsPerSecond(50))
+	if limited < 300*time.Millisecond || limited < unlimited {
+		t.Errorf("copies take %v at 50 requests per second and %v without limit, want at least 300ms when limited", limited, unlimited)
+	}
+}
@@ -1,11 +1,18 @@
 package s3copier
 
 import (
+	"context"
 	"fmt"
 	"math"
+	"net/http"
+	"net/url"
+	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 )
@@ -13,206 +20,1020 @@ import (
 // https://github.com/aws/aws-sdk-ruby/blob/97b28ccf18558fc908fd56f52741cf3329de9869/gems/aws-sdk-s3/lib/aws-sdk-s3/object_multipart_copier.rb
 
 const (
-	FIVE_MB      = 5 * 1024 * 1024
-	WORKER_COUNT = 50
+	ONE_MB         = 1024 * 1024
+	FIVE_MB        = 5 * ONE_MB
+	MAX_PART_SIZE  = 5 * 1024 * 1024 * 1024
+	MAX_PART_COUNT = 10000
+	WORKER_COUNT   = 50
+	// 1つの object の part を同時にいくつコピーするか
+	PART_CONCURRENCY = 10
+	// 列挙した key と結果のバッファ
+	CHANNEL_BUFFER = 20000
+	// AbortMultipartUpload 1回と Close 全体の待ち時間の上限
+	DRAIN_TIMEOUT = 30 * time.Second
+
+	CONTENT_TYPE_M3U8 = "application/vnd.apple.mpegurl"
+
+	SKIP_REASON_UNCHANGED      = "unchanged at destination"
+	SKIP_REASON_HEAD_PREDICATE = "excluded by head predicate"
+	SKIP_REASON_NOT_MODIFIED   = "not modified since destination"
+	SKIP_REASON_EXISTS         = "destination exists"
+	SKIP_REASON_MISSING        = "source not found"
 )
 
 type S3Object struct {
 	bucket string
 	key    string
+	// 空なら最新の version
+	versionId string
+}
+
+// NewS3Object returns the object at bucket/key, to be passed to CopyTo.
+func NewS3Object(bucket, key string) *S3Object {
+	return &S3Object{bucket: bucket, key: key}
+}
+
+// NewS3ObjectVersion returns the given version of the object at bucket/key, to be passed to CopyTo
+// as the source. Destination objects can't have a version id, S3 assigns a new one.
+func NewS3ObjectVersion(bucket, key, versionId string) *S3Object {
+	return &S3Object{bucket: bucket, key: key, versionId: versionId}
+}
+
+func (s *S3Object) Bucket() string {
+	return s.bucket
+}
+
+func (s *S3Object) Key() string {
+	return s.key
+}
+
+func (s *S3Object) VersionId() string {
+	return s.versionId
 }
 
 func (s *S3Object) bucketKeyPath() string {
 	return fmt.Sprintf("%s/%s", s.bucket, s.key)
 }
 
+// copySource returns the CopySource of CopyObject and UploadPartCopy for s,
+// with the key URL-encoded as S3 requires.
+func (s *S3Object) copySource() string {
+	segments := strings.Split(s.key, "/")
+	for i, segment := range segments {
+		// QueryEscape は空白を + にするので %20 に直す。元の + は %2B になっている
+		segments[i] = strings.Replace(url.QueryEscape(segment), "+", "%20", -1)
+	}
+	source := fmt.Sprintf("%s/%s", s.bucket, strings.Join(segments, "/"))
+	if s.versionId == "" {
+		return source
+	}
+	return fmt.Sprintf("%s?versionId=%s", source, url.QueryEscape(s.versionId))
+}
+
+func (s *S3Object) versionIdValue() *string {
+	if s.versionId == "" {
+		return nil
+	}
+	return aws.String(s.versionId)
+}
+
+// S3Copier copies S3 objects server side. It is safe for concurrent use; simultaneous
+// CopyWithPrefix calls on one copier keep their own results and statistics.
 type S3Copier struct {
-	partSize int64
-	s3client *s3.S3
+	partSize        int64
+	workerCount     int
+	partConcurrency int
+	channelBuffer   int
+	// source の読み込みと destination への書き込みで別の client を使う (リージョンが違う場合)
+	srcClient  S3Client
+	destClient S3Client
+	retryer    *retryer
+
+	decorators []RequestDecorator
+
+	metadataTransform func(map[string]*string) map[string]*string
+	skipExisting      bool
+	overwritePolicy   OverwritePolicy
+	useExistenceIndex bool
+	headPredicate     func(*s3.HeadObjectOutput) bool
+
+	sourceSSECustomerKey *sseCustomerKey
+	sseCustomerKey       *sseCustomerKey
+
+	failureReport *S3Object
+	throttle      *throttleAdapter
+
+	ifModifiedSinceDestination bool
+	priority                   func(key string) int
+	skippableSourceErrors      map[string]bool
+	verify                     bool
+	verifyRecopy               bool
+	verifyReadable             bool
+	verifyReadableThreshold    int64
+	twoPhaseCommit             func(key string) bool
+	hedging                    *hedging
+	progress                   func(ProgressEvent)
+	partProgress               func(key string, partNum, totalParts int64, bytes int64)
+	partProgressMu             sync.Mutex
+	dryRun                     bool
+	resume                     bool
+	multipartThreshold         int64
+	forceMultipart             bool
+	skipMissing                bool
+	metadataDirective          string
+	uploads                    activeUploads
+	drainTimeout               time.Duration
+	maxObjects                 int
+	modifiedAfter              time.Time
+	metrics                    MetricsRecorder
+	tracer                     Tracer
+	keyMapper                  func(srcKey string) string
+	storageClass               string
+	preserveTags               bool
+	preserveACL                bool
+	contentTypes               map[string]string
+	continueOnError            bool
+	includeFilters             []*regexp.Regexp
+	excludeFilters             []*regexp.Regexp
+	delimiter                  string
+	adaptivePartSize           bool
+	logger                     Logger
+
+	// 最後に開始した CopyWithPrefix のもの
+	statsMu sync.Mutex
+	stats   *runStats
+	// 同時に実行された CopyWithPrefix からも progress が並行に呼ばれないようにする
+	progressMu sync.Mutex
 }
 
-func NewS3Copier(sess *session.Session) *S3Copier {
-	return &S3Copier{
-		s3client: s3.New(sess),
+// NewS3Copier creates a copier using the S3 client configuration of sess as is,
+// so a custom Endpoint and S3ForcePathStyle work for S3 compatible storages such as MinIO.
+func NewS3Copier(sess *session.Session, opts ...Option) *S3Copier {
+	return NewS3CopierWithClient(s3.New(sess), opts...)
+}
+
+// NewS3CopierCrossRegion creates a copier for buckets in different regions.
+// srcClient lists and reads the source objects, destClient sends the copy requests and
+// reads the destination, so each client must be configured for the region of its bucket.
+func NewS3CopierCrossRegion(srcClient, destClient S3Client, opts ...Option) *S3Copier {
+	c := &S3Copier{
+		srcClient:  srcClient,
+		destClient: destClient,
 		// rubyのsdkは 50Mだったのでそれぐらいで良さそう。一旦分割されるケースをみるために小さめで
-		partSize: FIVE_MB * 2,
+		partSize:        FIVE_MB * 2,
+		workerCount:     WORKER_COUNT,
+		partConcurrency: PART_CONCURRENCY,
+		channelBuffer:   CHANNEL_BUFFER,
+		drainTimeout:    DRAIN_TIMEOUT,
+		retryer:         newRetryer(),
+		logger:          nopLogger{},
+		metrics:         nopMetrics{},
+		tracer:          nopTracer{},
+		keyMapper:       identityKey,
+		preserveTags:    true,
+		contentTypes:    map[string]string{".m3u8": CONTENT_TYPE_M3U8},
+
+		multipartThreshold:      FIVE_MB,
+		verifyReadableThreshold: DEFAULT_VERIFY_READABLE_THRESHOLD,
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+	return c
 }
 
-func (c *S3Copier) runWorker(workerId int, srcBucket, destBucket string, jobs <-chan string, done chan<- string, statusChan chan<- error) {
-	for key := range jobs {
-		src := &S3Object{bucket: srcBucket, key: key}
-		dest := &S3Object{bucket: destBucket, key: key}
-		err := c.CopyTo(src, dest)
-		if err != nil {
-			statusChan <- err
+type jobResult struct {
+	key string
+	objectCopy
+	err error
+}
+
+// runWorker copies the keys from jobs until jobs is closed or ctx is done.
+// On error it reports to statusChan and cancels the run, so that the other workers and the listing stop too.
+// With continueOnError the error is reported to done instead and the worker goes on.
+func (c *S3Copier) runWorker(ctx context.Context, cancel context.CancelFunc, workerId int, srcBucket, destBucket string, stats *runStats, jobs <-chan string, done chan<- jobResult, statusChan chan<- jobResult) {
+	for {
+		key, ok := nextKey(ctx, jobs)
+		if !ok {
 			return
 		}
-		done <- key
+		stats.dequeue()
+		result := c.copyKey(ctx, srcBucket, destBucket, key)
+		if result.err != nil && !c.continueOnError {
+			select {
+			case statusChan <- jobResult{key: key, err: result.err}:
+			case <-ctx.Done():
+			}
+			cancel()
+			return
+		}
+		select {
+		case done <- result:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// nextKey receives the next key to copy from keys. It returns false when keys is closed or ctx is done.
+func nextKey(ctx context.Context, keys <-chan string) (string, bool) {
+	select {
+	case <-ctx.Done():
+		// キャンセルされたら新しい job は取らない
+		return "", false
+	case k, ok := <-keys:
+		return k, ok
+	}
+}
+
+// copyKey copies key of srcBucket to the key given by WithKeyMapper in destBucket.
+func (c *S3Copier) copyKey(ctx context.Context, srcBucket, destBucket, key string) jobResult {
+	src := &S3Object{bucket: srcBucket, key: key}
+	dest := &S3Object{bucket: destBucket, key: c.keyMapper(key)}
+	started := time.Now()
+	copied, err := c.copyObject(ctx, src, dest)
+	if err == nil && copied.skipReason == "" {
+		c.metrics.ObserveCopyDuration(time.Since(started))
+	}
+	return jobResult{key: key, objectCopy: copied, err: err}
+}
+
+// observeResult logs the result of a key and records it in the metrics.
+func (c *S3Copier) observeResult(result jobResult) {
+	switch {
+	case result.err != nil:
+		c.logger.Errorf("%s failed: %v", result.key, result.err)
+		c.metrics.IncErrors()
+	case result.skipReason != "":
+		c.logger.Infof("%s skipped: %s", result.key, result.skipReason)
+	default:
+		c.logger.Infof("%s copied.", result.key)
+		c.metrics.IncObjectsCopied()
+		c.metrics.AddBytesCopied(result.size)
 	}
 }
 
 func (c *S3Copier) CopyWithPrefix(srcBucket, destBucket, prefix string) error {
-	count := 0
-	jobs := make(chan string, 20000)
-	done := make(chan string, 20000)
-	statusChan := make(chan error, 1)
+	return c.CopyWithPrefixContext(context.Background(), srcBucket, destBucket, prefix)
+}
+
+// CopyWithPrefixContext is CopyWithPrefix which stops when ctx is cancelled.
+// Workers stop taking new keys, in-flight multipart uploads are aborted and ctx.Err() is returned.
+func (c *S3Copier) CopyWithPrefixContext(ctx context.Context, srcBucket, destBucket, prefix string) error {
+	_, err := c.CopyWithPrefixResultContext(ctx, srcBucket, destBucket, prefix)
+	return err
+}
+
+// CopyWithPrefixResult is CopyWithPrefix which also returns a summary of the run.
+func (c *S3Copier) CopyWithPrefixResult(srcBucket, destBucket, prefix string) (*CopyResult, error) {
+	return c.CopyWithPrefixResultContext(context.Background(), srcBucket, destBucket, prefix)
+}
+
+// CopyWithPrefixResultContext is CopyWithPrefixContext which also returns a summary of the run.
+// The result is returned even on error, counting the objects copied until then.
+func (c *S3Copier) CopyWithPrefixResultContext(ctx context.Context, srcBucket, destBucket, prefix string) (*CopyResult, error) {
+	return c.copyWithPrefix(ctx, srcBucket, destBucket, prefix, nil, false)
+}
+
+// CopyKeys copies the given keys from srcBucket to destBucket with the same workers and options
+// as CopyWithPrefix, without listing the source. WithPreCopyExistenceIndex is ignored,
+// since it needs the listing to compare the objects.
+func (c *S3Copier) CopyKeys(srcBucket, destBucket string, keys []string) error {
+	return c.CopyKeysContext(context.Background(), srcBucket, destBucket, keys)
+}
+
+// CopyKeysContext is CopyKeys which stops when ctx is cancelled.
+func (c *S3Copier) CopyKeysContext(ctx context.Context, srcBucket, destBucket string, keys []string) error {
+	_, err := c.copyWithPrefix(ctx, srcBucket, destBucket, "", keySource(keys), false)
+	return err
+}
+
+// MoveWithPrefix copies the objects under prefix as CopyWithPrefix does, deleting each source object
+// once it has been copied successfully. Source objects which failed or were skipped are kept.
+// The sources are deleted with DeleteObjects in batches of 1000 keys. Keys which failed to be deleted
+// don't stop the run and are returned as CopyErrors at the end. When the run stops on an error,
+// the sources copied in the last batch are kept, so running it again copies them again.
+func (c *S3Copier) MoveWithPrefix(srcBucket, destBucket, prefix string) error {
+	return c.MoveWithPrefixContext(context.Background(), srcBucket, destBucket, prefix)
+}
+
+// MoveWithPrefixContext is MoveWithPrefix which stops when ctx is cancelled.
+func (c *S3Copier) MoveWithPrefixContext(ctx context.Context, srcBucket, destBucket, prefix string) error {
+	_, err := c.copyWithPrefix(ctx, srcBucket, destBucket, prefix, nil, true)
+	return err
+}
+
+// objectSource calls fn for each source object to copy until fn returns false.
+type objectSource func(ctx context.Context, fn func(obj *s3.Object) bool) error
 
-	for w := 0; w < WORKER_COUNT; w++ {
-		go c.runWorker(w, srcBucket, destBucket, jobs, done, statusChan)
+func keySource(keys []string) objectSource {
+	return func(ctx context.Context, fn func(obj *s3.Object) bool) error {
+		for _, k := range keys {
+			if !fn(&s3.Object{Key: aws.String(k)}) {
+				break
+			}
+		}
+		return nil
 	}
+}
 
-	c.s3client.ListObjectsV2Pages(&s3.ListObjectsV2Input{
-		Bucket: aws.String(srcBucket),
-		Prefix: aws.String(prefix),
-	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
-		for _, c := range page.Contents {
-			k := *c.Key
-			// log.Infof("ListObjectsV2Output: add key to jobs: %s\n", k)
-			jobs <- k
-			count++
+func (c *S3Copier) listSource(bucket, prefix string) objectSource {
+	return func(ctx context.Context, fn func(obj *s3.Object) bool) error {
+		input := &s3.ListObjectsV2Input{
+			Bucket: aws.String(bucket),
+			Prefix: aws.String(prefix),
 		}
+		if c.delimiter != "" {
+			input.Delimiter = aws.String(c.delimiter)
+		}
+		c.decorate(OP_LIST_OBJECTS_V2, input)
+		// Delimiter を指定した場合の CommonPrefixes は object ではないので Contents だけ見る
+		return c.srcClient.ListObjectsV2PagesWithContext(ctx, input, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+			for _, obj := range page.Contents {
+				if !fn(obj) {
+					return false
+				}
+			}
+			return true
+		})
+	}
+}
+
+// copyWithPrefix copies the objects from source, or the ones listed under prefix when source is nil.
+func (c *S3Copier) copyWithPrefix(ctx context.Context, srcBucket, destBucket, prefix string, source objectSource, move bool) (copyResult *CopyResult, err error) {
+	started := time.Now()
+	copyResult = &CopyResult{}
+	defer func() {
+		copyResult.Duration = time.Since(started)
+	}()
+
+	parent := ctx
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	jobs := make(chan string, c.channelBuffer)
+	done := make(chan jobResult, c.channelBuffer)
+	statusChan := make(chan jobResult, 1)
+	stats := c.startStats(jobs)
+
+	var failures, skipped []jobResult
+	if c.failureReport != nil {
+		defer func() {
+			// キャンセルされていてもレポートは残す
+			reportErr := c.writeFailureReport(context.Background(), srcBucket, destBucket, prefix, failures, skipped)
+			if reportErr != nil {
+				c.logger.Errorf("failed to write failure report: %v", reportErr)
+				if err == nil {
+					err = reportErr
+				}
+			}
+		}()
+	}
+
+	var index existenceIndex
+	if c.useExistenceIndex && source == nil {
+		var err error
+		index, err = c.buildExistenceIndex(ctx, destBucket, c.keyMapper(prefix))
+		if err != nil {
+			return copyResult, err
+		}
+	}
+
+	// worker と列挙の goroutine が done に送るので、全て終わってから close する
+	var senders sync.WaitGroup
+	for w := 0; w < c.workerCount; w++ {
+		senders.Add(1)
+		go func(w int) {
+			defer senders.Done()
+			c.runWorker(ctx, cancel, w, srcBucket, destBucket, stats, jobs, done, statusChan)
+		}(w)
+	}
+	// 途中で return した場合も worker と列挙を止めて終了を待つ
+	defer func() {
+		cancel()
+		senders.Wait()
+	}()
+
+	enqueue := func(k string) bool {
+		c.logger.Debugf("ListObjectsV2Output: add key to jobs: %s", k)
+		select {
+		case jobs <- k:
+		case <-ctx.Done():
+			return false
+		}
+		stats.enqueue()
 		return true
-	})
+	}
+
+	fail := func(result jobResult) (*CopyResult, error) {
+		c.logger.Errorf("raise error: %v", result.err)
+		c.metrics.IncErrors()
+		failures = append(failures, result)
+		return copyResult, result.err
+	}
+	// ctx が done になった理由を返す。worker のエラーなら statusChan に入っている
+	stopped := func() (*CopyResult, error) {
+		if parent.Err() != nil {
+			return copyResult, parent.Err()
+		}
+		select {
+		case result := <-statusChan:
+			return fail(result)
+		default:
+			return copyResult, ctx.Err()
+		}
+	}
+
+	var pendingDeletes []string
+	flushDeletes := func() {
+		if len(pendingDeletes) == 0 {
+			return
+		}
+		for _, ce := range c.deleteSources(ctx, srcBucket, pendingDeletes) {
+			c.logger.Errorf("%s failed: %v", ce.Key, ce.Err)
+			c.metrics.IncErrors()
+			failures = append(failures, jobResult{key: ce.Key, err: ce.Err})
+			copyResult.Errors = append(copyResult.Errors, ce)
+		}
+		pendingDeletes = pendingDeletes[:0]
+	}
+
+	listFailed := func(listErr error) (*CopyResult, error) {
+		if ctx.Err() != nil {
+			return stopped()
+		}
+		// 一部しか列挙できていないので成功扱いにしない。return で cancel されて worker も止まる
+		c.logger.Errorf("failed to list %s/%s: %v", srcBucket, prefix, listErr)
+		return copyResult, listErr
+	}
+
+	if source == nil {
+		source = c.listSource(srcBucket, prefix)
+	}
+	// 列挙は完了を待つループと並行して行う。列挙し終えるまで jobs は close されないので、
+	// 列挙途中のページの job が全て終わっても完了とはみなされない
+	listDone := make(chan error, 1)
+	senders.Add(1)
+	go func() {
+		defer senders.Done()
+		var prioritized []string
+		enqueued := 0
+		listErr := source(ctx, func(obj *s3.Object) bool {
+			k := *obj.Key
+			if !c.matchesFilters(k) || !c.isModifiedAfter(obj) {
+				// 対象外なので結果にも含めない
+				return true
+			}
+			if index.contains(c.keyMapper(k), obj) {
+				select {
+				case done <- jobResult{key: k, objectCopy: objectCopy{skipReason: SKIP_REASON_UNCHANGED}}:
+					return true
+				case <-ctx.Done():
+					return false
+				}
+			}
+			if c.priority != nil {
+				prioritized = append(prioritized, k)
+				return true
+			}
+			if !enqueue(k) {
+				return false
+			}
+			enqueued++
+			// 上限に達したら列挙もやめる
+			return c.maxObjects == 0 || enqueued < c.maxObjects
+		})
+		if listErr == nil {
+			prioritized = c.sortByPriority(prioritized)
+			if c.maxObjects > 0 && len(prioritized) > c.maxObjects {
+				prioritized = prioritized[:c.maxObjects]
+			}
+			for _, k := range prioritized {
+				if !enqueue(k) {
+					break
+				}
+			}
+		}
+		// 全て enqueue したので worker は残りの job を処理したら終了する
+		close(jobs)
+		listDone <- listErr
+	}()
+	go func() {
+		senders.Wait()
+		close(done)
+	}()
 
 	check := 0
 	for {
 		select {
-		case key := <-done:
-			fmt.Printf("%s copied.\n", key)
+		case result, ok := <-done:
+			if !ok {
+				// worker も列挙も終わった
+				if listDone != nil {
+					if listErr := <-listDone; listErr != nil {
+						return listFailed(listErr)
+					}
+				}
+				if ctx.Err() != nil {
+					return stopped()
+				}
+				flushDeletes()
+				// 正常
+				if len(copyResult.Errors) > 0 {
+					// WithContinueOnError の場合は最後にまとめて返す
+					return copyResult, CopyErrors(copyResult.Errors)
+				}
+				return copyResult, nil
+			}
+			if result.err != nil && parent.Err() != nil {
+				return copyResult, parent.Err()
+			}
+			c.observeResult(result)
+			if result.err != nil {
+				failures = append(failures, result)
+				copyResult.Errors = append(copyResult.Errors, CopyError{Key: result.key, Err: result.err})
+			} else if result.skipReason != "" {
+				skipped = append(skipped, result)
+				copyResult.Skipped++
+				copyResult.SkippedObjects = append(copyResult.SkippedObjects, SkippedObject{Key: result.key, Reason: result.skipReason})
+				if result.skipReason == SKIP_REASON_MISSING {
+					copyResult.Missing = append(copyResult.Missing, result.key)
+				}
+			} else {
+				copyResult.add(result.objectCopy)
+				if move && !c.dryRun {
+					// コピーに成功したものだけ source を消す
+					pendingDeletes = append(pendingDeletes, result.key)
+					if len(pendingDeletes) == DELETE_BATCH_SIZE {
+						flushDeletes()
+					}
+				}
+			}
 			check++
-		case err := <-statusChan:
-			fmt.Printf("raise error: %v\n", err)
+			c.reportProgress(ProgressEvent{
+				Key:       result.key,
+				Size:      result.size,
+				Multipart: result.multipart,
+				Skipped:   result.skipReason != "",
+				Err:       result.err,
+				Completed: check,
+			})
+		case listErr := <-listDone:
+			listDone = nil
+			if listErr != nil {
+				return listFailed(listErr)
+			}
+		case result := <-statusChan:
+			if parent.Err() != nil {
+				// キャンセルで中断されたリクエストのエラー
+				return copyResult, parent.Err()
+			}
 			// 途中でエラー発生
-			return err
-		}
-		if check >= count {
-			// 正常
-			return nil
+			return fail(result)
+		case <-ctx.Done():
+			return stopped()
 		}
 	}
 }
 
 func (c *S3Copier) CopyTo(src *S3Object, dest *S3Object) error {
-	head, err := c.headObject(src)
+	return c.CopyToContext(context.Background(), src, dest)
+}
+
+// CopyToContext is CopyTo which stops when ctx is cancelled, aborting the multipart upload in progress.
+func (c *S3Copier) CopyToContext(ctx context.Context, src *S3Object, dest *S3Object) error {
+	_, err := c.copyObject(ctx, src, dest)
+	return err
+}
+
+// objectCopy describes how an object was copied.
+type objectCopy struct {
+	// コピーしなかった場合にその理由が入る
+	skipReason string
+	size       int64
+	multipart  bool
+}
+
+// copyObject is CopyTo which also returns how the object was copied or why it was skipped.
+func (c *S3Copier) copyObject(ctx context.Context, src *S3Object, dest *S3Object) (copied objectCopy, err error) {
+	ctx, span := c.tracer.Start(ctx, SPAN_COPY_OBJECT)
+	span.SetAttribute("s3copier.source", src.bucketKeyPath())
+	span.SetAttribute("s3copier.destination", dest.bucketKeyPath())
+	defer func() {
+		if copied.skipReason != "" {
+			span.SetAttribute("s3copier.skip_reason", copied.skipReason)
+		}
+		span.End(err)
+	}()
+
+	head, err := c.headObject(ctx, c.srcClient, src, c.sourceSSECustomerKey)
+	if c.isSkippableSourceError(err) {
+		return objectCopy{skipReason: fmt.Sprintf("source error: %v", err)}, nil
+	}
+	if c.skipMissing && isNotFound(err) {
+		// 列挙してからコピーするまでの間に消された
+		return objectCopy{skipReason: SKIP_REASON_MISSING}, nil
+	}
 	if err != nil {
-		return err
+		return objectCopy{}, classifyError(src, err)
 	}
 
-	if strings.HasSuffix(src.key, ".m3u8") {
-		if err := c.ensureContentTypeM3u8(src); err != nil {
-			return err
+	if c.headPredicate != nil && !c.headPredicate(head) {
+		return objectCopy{skipReason: SKIP_REASON_HEAD_PREDICATE}, nil
+	}
+
+	var ifModifiedSince *time.Time
+	if c.skipExisting || c.overwritePolicy != OverwriteAlways || c.ifModifiedSinceDestination {
+		destHead, err := c.destinationHead(ctx, dest)
+		if err != nil {
+			return objectCopy{}, err
+		}
+		if destHead != nil {
+			if c.skipExisting && isUnchanged(head, destHead) {
+				return objectCopy{skipReason: SKIP_REASON_UNCHANGED}, nil
+			}
+			switch c.overwritePolicy {
+			case OverwriteSkip:
+				return objectCopy{skipReason: SKIP_REASON_EXISTS}, nil
+			case OverwriteFail:
+				return objectCopy{}, fmt.Errorf("%s: %w", dest.bucketKeyPath(), ErrDestinationExists)
+			}
+			if c.ifModifiedSinceDestination {
+				ifModifiedSince = destHead.LastModified
+			}
 		}
-		// log.Infof("CopyTo: ContentTypeUpdated %v\n", src)
 	}
 
 	objectSize := *head.ContentLength
-	if objectSize <= FIVE_MB {
-		return c.copyToSinglePart(src, dest)
-	} else {
-		return c.copyToMultiPart(src, dest)
+	span.SetAttribute("s3copier.size", objectSize)
+	// 0 byte の object も含め、ちょうど閾値 (デフォルト 5MB) までは CopyObject 1回でコピーする
+	multipart := objectSize > c.multipartThreshold
+	if c.forceMultipart && objectSize > 0 {
+		// 0 byte は range を指定できないので multipart にできない
+		multipart = true
+	}
+	if c.dryRun {
+		c.logger.Infof("dry run: %s -> %s (%d bytes)", src.bucketKeyPath(), dest.bucketKeyPath(), objectSize)
+		return objectCopy{size: objectSize, multipart: multipart}, nil
 	}
-}
 
-func (c *S3Copier) ensureContentTypeM3u8(src *S3Object) error {
-	_, err := c.s3client.CopyObject(&s3.CopyObjectInput{
-		Bucket:            aws.String(src.bucket),
-		Key:               aws.String(src.key),
-		ContentType:       aws.String("application/vnd.apple.mpegurl"),
-		CopySource:        aws.String(src.bucketKeyPath()),
-		MetadataDirective: aws.String(s3.MetadataDirectiveReplace),
-	})
-	return err
+	target := dest
+	if c.twoPhaseCommit != nil && c.twoPhaseCommit(dest.key) {
+		if objectSize > MAX_COPY_OBJECT_SIZE {
+			return objectCopy{}, fmt.Errorf("%s: two-phase commit can't promote objects larger than %d bytes", dest.bucketKeyPath(), int64(MAX_COPY_OBJECT_SIZE))
+		}
+		target = temporaryObject(dest)
+		defer c.deleteTemporary(target)
+	}
+
+	copyTo := func(ifModifiedSince *time.Time) error {
+		var err error
+		if !multipart {
+			err = c.copyToSinglePart(ctx, src, target, head, ifModifiedSince)
+		} else {
+			err = c.copyToMultiPart(ctx, src, target, head, ifModifiedSince)
+		}
+		if err != nil {
+			return err
+		}
+		if target != dest {
+			return c.promoteTemporary(ctx, src, target, dest, c.storageClassFor(head))
+		}
+		return nil
+	}
+	err = copyTo(ifModifiedSince)
+	if ifModifiedSince != nil && isPreconditionFailed(err) {
+		// destination の方が新しいので S3 側でコピーされなかった
+		return objectCopy{skipReason: SKIP_REASON_NOT_MODIFIED}, nil
+	}
+	if isNotFound(err) {
+		// head の後に source が消された
+		return objectCopy{}, classifyError(src, err)
+	}
+	if statusCode(err) == http.StatusForbidden {
+		return objectCopy{}, classifyError(dest, err)
+	}
+	if isLimitExceeded(err) {
+		return objectCopy{}, classifyError(src, err)
+	}
+	if err != nil {
+		return objectCopy{}, err
+	}
+
+	if c.verify {
+		err := c.verifyCopy(ctx, head, dest)
+		if err != nil && c.verifyRecopy {
+			c.logger.Infof("%s: copying again: %v", dest.bucketKeyPath(), err)
+			// 1回目で destination は更新されているので IfModifiedSince は付けない
+			err = copyTo(nil)
+			if err == nil {
+				err = c.verifyCopy(ctx, head, dest)
+			}
+		}
+		if err != nil {
+			return objectCopy{}, err
+		}
+	}
+	if c.verifyReadable {
+		if err := c.verifyDestinationReadable(ctx, dest); err != nil {
+			return objectCopy{}, err
+		}
+	}
+	return objectCopy{size: objectSize, multipart: multipart}, nil
 }
 
-func (c *S3Copier) copyToSinglePart(src *S3Object, dest *S3Object) error {
-	_, err := c.s3client.CopyObject(&s3.CopyObjectInput{
-		Bucket:     aws.String(dest.bucket),
-		Key:        aws.String(dest.key),
-		CopySource: aws.String(src.bucketKeyPath()),
+func (c *S3Copier) copyToSinglePart(ctx context.Context, src *S3Object, dest *S3Object, srcHead *s3.HeadObjectOutput, ifModifiedSince *time.Time) error {
+	input := &s3.CopyObjectInput{
+		Bucket:                    aws.String(dest.bucket),
+		Key:                       aws.String(dest.key),
+		CopySource:                aws.String(src.copySource()),
+		CopySourceIfModifiedSince: ifModifiedSince,
+		StorageClass:              c.storageClassFor(srcHead),
+	}
+	if !c.preserveTags {
+		// TaggingDirective=COPY (デフォルト) だとタグがそのまま引き継がれる
+		input.TaggingDirective = aws.String(s3.TaggingDirectiveReplace)
+	}
+	if c.preserveACL {
+		grants, err := c.sourceGrants(ctx, c.srcClient, src)
+		if err != nil {
+			return err
+		}
+		input.GrantRead, input.GrantReadACP, input.GrantWriteACP, input.GrantFullControl = grants[grantRead], grants[grantReadACP], grants[grantWriteACP], grants[grantFullControl]
+	}
+	metadata, contentType := c.destinationMetadata(src, srcHead)
+	replace := metadata != nil || aws.StringValue(contentType) != aws.StringValue(srcHead.ContentType)
+	switch c.metadataDirective {
+	case s3.MetadataDirectiveReplace:
+		replace = true
+	case s3.MetadataDirectiveCopy:
+		input.MetadataDirective = aws.String(s3.MetadataDirectiveCopy)
+		replace = false
+	}
+	if replace {
+		if metadata == nil {
+			metadata = srcHead.Metadata
+		}
+		// REPLACE にするとシステムメタデータも引き継がれないので明示的に設定する
+		input.MetadataDirective = aws.String(s3.MetadataDirectiveReplace)
+		input.Metadata = metadata
+		input.ContentType = contentType
+		input.CacheControl = srcHead.CacheControl
+		input.ContentDisposition = srcHead.ContentDisposition
+		input.ContentEncoding = srcHead.ContentEncoding
+		input.ContentLanguage = srcHead.ContentLanguage
+		input.WebsiteRedirectLocation = srcHead.WebsiteRedirectLocation
+	}
+	c.decorate(OP_COPY_OBJECT, input)
+	err := c.retryer.do(ctx, func() error {
+		_, err := c.destClient.CopyObjectWithContext(ctx, input)
+		return err
 	})
-	// log.Infof("copyToSinglePart:%v -> %v, err: %v\n", src, dest, err)
+	c.logger.Debugf("copyToSinglePart:%v -> %v, err: %v", src.bucketKeyPath(), dest.bucketKeyPath(), err)
 	return err
 }
 
-func (c *S3Copier) copyToMultiPart(src *S3Object, dest *S3Object) error {
-	head, err := c.headObject(src)
-	if err != nil {
-		return err
-	}
+// copyToMultiPart copies src with the head copyObject has already got, to save a HeadObject per object.
+func (c *S3Copier) copyToMultiPart(ctx context.Context, src *S3Object, dest *S3Object, head *s3.HeadObjectOutput, ifModifiedSince *time.Time) (err error) {
+	objectSize := *head.ContentLength
+	c.logger.Debugf("copyToMultiPart:from %v objectSize: %v", src.bucketKeyPath(), objectSize)
+	// resume する upload の part と揃えるために先に決める。partSizeFor で MAX_PART_COUNT 以内に収まる
+	partSize := c.partSizeFor(objectSize)
+	partsSize := int(math.Ceil(float64(objectSize) / float64(partSize)))
 
-	multipartUploadInit, err := c.createMultiPartUpload(dest, head)
-	if err != nil {
-		return err
+	var uploadId *string
+	var uploaded map[int64]uploadedPart
+	if c.resume {
+		uploadId, uploaded, err = c.findResumableUpload(ctx, dest, head, partSize)
+		if err != nil {
+			return err
+		}
 	}
+	if uploadId == nil {
+		multipartUploadInit, err := c.createMultiPartUpload(ctx, src, dest, head)
+		if err != nil {
+			return err
+		}
+		uploadId = multipartUploadInit.UploadId
+	}
+	c.uploads.add(*uploadId, dest)
+	defer func() {
+		if err != nil {
+			// コピー済みの part が残り続けないように破棄する
+			c.abortMultipartUpload(dest, uploadId)
+		}
+	}()
 
-	objectSize := *head.ContentLength
-	// log.Infof("copyToMultiPart:from % objectSize: %v\n", src, objectSize)
-	bytePosition := int64(0)
-	partNum := int64(1)
-	partsSize := int(math.Ceil(float64(objectSize) / float64(c.partSize)))
-	// log.Infof("copyToMultiPart:partSize %v\n", partsSize)
+	c.logger.Debugf("copyToMultiPart:partSize %v", partsSize)
 	completedParts := make([]*s3.CompletedPart, partsSize)
 
-	for bytePosition < objectSize {
-		lastByte := bytePosition + c.partSize - 1
-		if lastByte > objectSize-1 {
-			lastByte = objectSize - 1
-		}
+	// どこかの part が失敗したら残りの part のコピーも止める
+	partCtx, cancelParts := context.WithCancel(ctx)
+	defer cancelParts()
+	partNums := make(chan int64)
+	partErrs := make(chan error, c.partConcurrency)
+	var wg sync.WaitGroup
+	for w := 0; w < c.partConcurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for partNum := range partNums {
+				bytePosition := (partNum - 1) * partSize
+				lastByte := bytePosition + partSize - 1
+				if lastByte > objectSize-1 {
+					lastByte = objectSize - 1
+				}
+				if part, ok := uploaded[partNum]; ok && part.size == lastByte-bytePosition+1 {
+					// 前回の upload でコピー済みの part はそのまま使う
+					completedParts[partNum-1] = &s3.CompletedPart{
+						ETag:       aws.String(part.etag),
+						PartNumber: aws.Int64(partNum),
+					}
+					c.reportPartProgress(src.key, partNum, int64(partsSize), part.size)
+					continue
+				}
 
-		partResult, err := c.uploadPartCopy(
-			partNum,
-			src,
-			dest,
-			bytePosition,
-			lastByte,
-			multipartUploadInit.UploadId,
-		)
-		if err != nil {
-			return nil
-		}
+				partResult, err := c.uploadPartCopy(
+					partCtx,
+					partNum,
+					src,
+					dest,
+					bytePosition,
+					lastByte,
+					uploadId,
+					ifModifiedSince,
+				)
+				if err != nil {
+					partErrs <- err
+					cancelParts()
+					return
+				}
 
-		etag := *partResult.CopyPartResult.ETag
-		completedParts[partNum-1] = &s3.CompletedPart{
-			ETag:       aws.String(etag[1 : len(etag)-1]), // escape duble quote
-			PartNumber: aws.Int64(partNum),
+				var etag string
+				if partResult.CopyPartResult != nil {
+					// ダブルクォートで囲まれていない場合もあるので Trim する
+					etag = strings.Trim(aws.StringValue(partResult.CopyPartResult.ETag), "\"")
+				}
+				if etag == "" {
+					partErrs <- fmt.Errorf("%s: UploadPartCopy of part %d returned no ETag", dest.bucketKeyPath(), partNum)
+					cancelParts()
+					return
+				}
+				// index は part ごとに別なので lock は不要
+				completedParts[partNum-1] = &s3.CompletedPart{
+					ETag:       aws.String(etag),
+					PartNumber: aws.Int64(partNum),
+				}
+				c.reportPartProgress(src.key, partNum, int64(partsSize), lastByte-bytePosition+1)
+			}
+		}()
+	}
+
+feed:
+	for partNum := int64(1); partNum <= int64(partsSize); partNum++ {
+		select {
+		case partNums <- partNum:
+		case <-partCtx.Done():
+			break feed
 		}
-		partNum++
-		bytePosition += c.partSize
+	}
+	close(partNums)
+	wg.Wait()
+	close(partErrs)
+	// 最初に失敗した part のエラーを返す
+	if err, ok := <-partErrs; ok {
+		return err
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
 	}
 	// ここまでで分割したやつの処理終わり
 
-	_, err = c.s3client.CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{
+	if err := verifyCompletedParts(completedParts, partsSize); err != nil {
+		return fmt.Errorf("%s: %v", dest.bucketKeyPath(), err)
+	}
+
+	completeInput := &s3.CompleteMultipartUploadInput{
 		Bucket: aws.String(dest.bucket),
 		Key:    aws.String(dest.key),
 		MultipartUpload: &s3.CompletedMultipartUpload{
 			Parts: completedParts,
 		},
-		UploadId: multipartUploadInit.UploadId,
+		UploadId: uploadId,
+	}
+	c.decorate(OP_COMPLETE_MULTIPART_UPLOAD, completeInput)
+	completeCtx, span := c.tracer.Start(ctx, SPAN_COMPLETE_MULTIPART_UPLOAD)
+	span.SetAttribute("s3copier.destination", dest.bucketKeyPath())
+	span.SetAttribute("s3copier.parts", int64(partsSize))
+	err = c.retryer.do(completeCtx, func() error {
+		output, err := c.destClient.CompleteMultipartUploadWithContext(completeCtx, completeInput)
+		if err == nil && aws.StringValue(output.ETag) == "" {
+			// 200 でもボディがエラーの場合がある。S3 のエラーと同様に retry し、だめなら abort する
+			// Location は S3 互換ストレージだと返らないことがあるので見ない
+			return awserr.New("InternalError", "CompleteMultipartUpload returned no ETag", nil)
+		}
+		return err
 	})
+	span.End(err)
 
-	// log.Infof("copyToMultiPart:%v -> %v, err: %v\n", src, dest, err)
+	c.logger.Debugf("copyToMultiPart:%v -> %v, err: %v", src.bucketKeyPath(), dest.bucketKeyPath(), err)
 	if err != nil {
 		return err
 	}
+	c.uploads.remove(*uploadId)
 	return nil
 }
 
-func (c *S3Copier) uploadPartCopy(partNum int64, src *S3Object, dest *S3Object, bytePosition int64, lastByte int64, uploadId *string) (*s3.UploadPartCopyOutput, error) {
-	return c.s3client.UploadPartCopy(&s3.UploadPartCopyInput{
-		Bucket:          aws.String(dest.bucket),
-		CopySource:      aws.String(src.bucketKeyPath()),
-		CopySourceRange: aws.String(fmt.Sprintf("bytes=%d-%d", bytePosition, lastByte)),
-		Key:             aws.String(dest.key),
-		PartNumber:      aws.Int64(partNum),
-		UploadId:        uploadId,
+// partSizeFor returns the part size to copy an object of objectSize bytes with.
+// S3 allows at most 10000 parts per upload, so the part size is raised for objects which wouldn't fit.
+func (c *S3Copier) partSizeFor(objectSize int64) int64 {
+	partSize := c.partSize
+	if c.throttle != nil {
+		if adapted := c.throttle.currentPartSize(); adapted > partSize {
+			partSize = adapted
+		}
+	}
+	if objectSize > partSize*MAX_PART_COUNT {
+		adjusted := (objectSize + MAX_PART_COUNT - 1) / MAX_PART_COUNT
+		c.logger.Infof("part size is increased from %d to %d bytes to copy %d bytes within %d parts", partSize, adjusted, objectSize, MAX_PART_COUNT)
+		partSize = adjusted
+	}
+	if c.adaptivePartSize {
+		// part の境界がきりの良い値になるように 1MB 単位に切り上げる
+		partSize = (partSize + ONE_MB - 1) / ONE_MB * ONE_MB
+	}
+	return partSize
+}
+
+// verifyCompletedParts checks that every planned part has been copied before completing the upload,
+// since CompleteMultipartUpload only rejects a missing part with a cryptic error.
+func verifyCompletedParts(completedParts []*s3.CompletedPart, partsSize int) error {
+	if len(completedParts) != partsSize {
+		return fmt.Errorf("internal consistency error: %d parts are planned but %d are completed", partsSize, len(completedParts))
+	}
+	for i, part := range completedParts {
+		if part == nil {
+			return fmt.Errorf("internal consistency error: part %d of %d is missing", i+1, partsSize)
+		}
+	}
+	return nil
+}
+
+func (c *S3Copier) uploadPartCopy(ctx context.Context, partNum int64, src *S3Object, dest *S3Object, bytePosition int64, lastByte int64, uploadId *string, ifModifiedSince *time.Time) (*s3.UploadPartCopyOutput, error) {
+	ctx, span := c.tracer.Start(ctx, SPAN_UPLOAD_PART_COPY)
+	span.SetAttribute("s3copier.destination", dest.bucketKeyPath())
+	span.SetAttribute("s3copier.part_number", partNum)
+	span.SetAttribute("s3copier.size", lastByte-bytePosition+1)
+
+	input := &s3.UploadPartCopyInput{
+		Bucket:                    aws.String(dest.bucket),
+		CopySource:                aws.String(src.copySource()),
+		CopySourceRange:           aws.String(fmt.Sprintf("bytes=%d-%d", bytePosition, lastByte)),
+		Key:                       aws.String(dest.key),
+		PartNumber:                aws.Int64(partNum),
+		UploadId:                  uploadId,
+		CopySourceIfModifiedSince: ifModifiedSince,
+	}
+	c.decorate(OP_UPLOAD_PART_COPY, input)
+	var output *s3.UploadPartCopyOutput
+	err := c.retryer.do(ctx, func() error {
+		var err error
+		if c.hedging != nil {
+			output, err = c.hedgedUploadPartCopy(ctx, input)
+		} else {
+			output, err = c.destClient.UploadPartCopyWithContext(ctx, input)
+		}
+		if c.throttle != nil {
+			if isThrottled(err) {
+				if partSize, increased := c.throttle.observe(lastByte - bytePosition + 1); increased {
+					c.logger.Infof("part size is increased to %d bytes by throttling", partSize)
+				}
+			} else if err == nil {
+				c.throttle.succeeded()
+			}
+		}
+		return err
 	})
+	span.End(err)
+	return output, err
 }
 
-func (c *S3Copier) headObject(obj *S3Object) (*s3.HeadObjectOutput, error) {
-	head, err := c.s3client.HeadObject(&s3.HeadObjectInput{
+func (c *S3Copier) headObject(ctx context.Context, client S3Client, obj *S3Object, sseKey *sseCustomerKey) (*s3.HeadObjectOutput, error) {
+	input := &s3.HeadObjectInput{
 		Bucket: aws.String(obj.bucket),
 		Key:    aws.String(obj.key),
+		// 指定した version の head を取る
+		VersionId: obj.versionIdValue(),
+
+		SSECustomerAlgorithm: sseKey.algorithmValue(),
+		SSECustomerKey:       sseKey.keyValue(),
+	}
+	c.decorate(OP_HEAD_OBJECT, input)
+	var head *s3.HeadObjectOutput
+	err := c.retryer.do(ctx, func() error {
+		var err error
+		head, err = client.HeadObjectWithContext(ctx, input)
+		return err
 	})
 	if err != nil {
 		return nil, err
@@ -220,17 +1041,87 @@ func (c *S3Copier) headObject(obj *S3Object) (*s3.HeadObjectOutput, error) {
 	return head, nil
 }
 
-func (c *S3Copier) createMultiPartUpload(dest *S3Object, srcHead *s3.HeadObjectOutput) (*s3.CreateMultipartUploadOutput, error) {
-	multiUploadInit, err := c.s3client.CreateMultipartUpload(&s3.CreateMultipartUploadInput{
-		Bucket:      aws.String(dest.bucket),
-		Key:         aws.String(dest.key),
-		ContentType: srcHead.ContentType,
-		Metadata:    srcHead.Metadata,
-	})
+func (c *S3Copier) createMultiPartUpload(ctx context.Context, src *S3Object, dest *S3Object, srcHead *s3.HeadObjectOutput) (*s3.CreateMultipartUploadOutput, error) {
+	// multipart では常に REPLACE 相当なので、COPY の場合も source のものを明示的に設定する
+	metadata, contentType := c.destinationMetadata(src, srcHead)
+	if metadata == nil {
+		metadata = srcHead.Metadata
+	}
+	var tagging *string
+	if c.preserveTags {
+		// multipart ではタグは引き継がれないので作成時に指定する
+		t, err := c.sourceTagging(ctx, src)
+		if err != nil {
+			return nil, err
+		}
+		tagging = t
+	}
+	var grants [4]*string
+	if c.preserveACL {
+		g, err := c.sourceGrants(ctx, c.srcClient, src)
+		if err != nil {
+			return nil, err
+		}
+		grants = g
+	}
+	input := &s3.CreateMultipartUploadInput{
+		Bucket:       aws.String(dest.bucket),
+		Key:          aws.String(dest.key),
+		ContentType:  contentType,
+		Metadata:     metadata,
+		StorageClass: c.storageClassFor(srcHead),
+		Tagging:      tagging,
 
+		// CopyObject と違い multipart ではシステムメタデータが引き継がれないので明示的に設定する
+		CacheControl:            srcHead.CacheControl,
+		ContentDisposition:      srcHead.ContentDisposition,
+		ContentEncoding:         srcHead.ContentEncoding,
+		ContentLanguage:         srcHead.ContentLanguage,
+		WebsiteRedirectLocation: srcHead.WebsiteRedirectLocation,
+
+		GrantRead:        grants[grantRead],
+		GrantReadACP:     grants[grantReadACP],
+		GrantWriteACP:    grants[grantWriteACP],
+		GrantFullControl: grants[grantFullControl],
+	}
+	c.decorate(OP_CREATE_MULTIPART_UPLOAD, input)
+	var multiUploadInit *s3.CreateMultipartUploadOutput
+	err := c.retryer.do(ctx, func() error {
+		var err error
+		multiUploadInit, err = c.destClient.CreateMultipartUploadWithContext(ctx, input)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	return multiUploadInit, nil
 }
+
+// abortMultipartUpload doesn't take the context of the copy, since it has to run even after the copy is
+// cancelled. It gives up after the drain timeout instead, so that a hanging abort can't block the run.
+func (c *S3Copier) abortMultipartUpload(dest *S3Object, uploadId *string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.drainTimeout)
+	defer cancel()
+	return c.abortMultipartUploadContext(ctx, dest, uploadId)
+}
+
+func (c *S3Copier) abortMultipartUploadContext(ctx context.Context, dest *S3Object, uploadId *string) error {
+	input := &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(dest.bucket),
+		Key:      aws.String(dest.key),
+		UploadId: uploadId,
+	}
+	c.decorate(OP_ABORT_MULTIPART_UPLOAD, input)
+	_, err := c.destClient.AbortMultipartUploadWithContext(ctx, input)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			// SDK のエラーからはタイムアウトかどうか判別できないので置き換える
+			err = errAbortTimedOut
+		}
+		c.logger.Errorf("failed to abort multipart upload %s of %s: %v", *uploadId, dest.bucketKeyPath(), err)
+		return err
+	}
+	c.uploads.remove(*uploadId)
+	return nil
+}
@@ -0,0 +1,1218 @@
+package s3copier
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// mockS3 is an in-memory S3 for the tests. The methods of s3iface.S3API the copier doesn't use
+// are not implemented and panic. Requests are recorded by operation name (the OP_* constants).
+type mockS3 struct {
+	s3iface.S3API
+
+	// 0 なら 1000
+	pageSize int
+	// hook が error を返すとリクエストはその error で失敗する
+	hook func(ctx aws.Context, op string, input interface{}) error
+
+	mu           sync.Mutex
+	objects      map[string]*mockObject
+	uploads      map[string]*mockUpload
+	nextUploadId int
+	requests     map[string][]interface{}
+	// DeleteObjects がこの key の削除に失敗する
+	failDeletes map[string]bool
+}
+
+type mockObject struct {
+	size         int64
+	etag         string
+	lastModified time.Time
+	storageClass string
+	contentType  string
+	metadata     map[string]*string
+	tags         url.Values
+	grants       []*s3.Grant
+	body         []byte
+
+	cacheControl            string
+	contentDisposition      string
+	contentEncoding         string
+	contentLanguage         string
+	websiteRedirectLocation string
+}
+
+type mockUpload struct {
+	bucket    string
+	key       string
+	initiated time.Time
+	input     *s3.CreateMultipartUploadInput
+	parts     map[int64]*s3.Part
+}
+
+func newMockS3() *mockS3 {
+	return &mockS3{
+		objects:     map[string]*mockObject{},
+		uploads:     map[string]*mockUpload{},
+		requests:    map[string][]interface{}{},
+		failDeletes: map[string]bool{},
+	}
+}
+
+func mockPath(bucket, key string) string {
+	return bucket + "/" + key
+}
+
+func mockETag(s string) string {
+	return fmt.Sprintf("\"%x\"", md5.Sum([]byte(s)))
+}
+
+func awsError(code string, status int) error {
+	return awserr.NewRequestFailure(awserr.New(code, code, nil), status, "request-id")
+}
+
+// put creates an object of size bytes and returns it to set the other attributes.
+func (m *mockS3) put(bucket, key string, size int64) *mockObject {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	obj := &mockObject{
+		size:         size,
+		etag:         mockETag(fmt.Sprintf("%s/%d", key, size)),
+		lastModified: time.Now().Add(-time.Hour),
+	}
+	m.objects[mockPath(bucket, key)] = obj
+	return obj
+}
+
+func (m *mockS3) object(bucket, key string) *mockObject {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.objects[mockPath(bucket, key)]
+}
+
+func (m *mockS3) count(op string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.requests[op])
+}
+
+func (m *mockS3) inputs(op string) []interface{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]interface{}{}, m.requests[op]...)
+}
+
+func (m *mockS3) record(ctx aws.Context, op string, input interface{}) error {
+	m.mu.Lock()
+	m.requests[op] = append(m.requests[op], input)
+	m.mu.Unlock()
+	if m.hook != nil {
+		if err := m.hook(ctx, op, input); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// parseCopySource returns the bucket and the key of a CopySource, ignoring the version id.
+func parseCopySource(copySource string) (string, string) {
+	if i := strings.Index(copySource, "?"); i >= 0 {
+		copySource = copySource[:i]
+	}
+	parts := strings.SplitN(copySource, "/", 2)
+	key, err := url.PathUnescape(parts[1])
+	if err != nil {
+		panic(err)
+	}
+	return parts[0], key
+}
+
+func (m *mockS3) HeadObjectWithContext(ctx aws.Context, in *s3.HeadObjectInput, _ ...request.Option) (*s3.HeadObjectOutput, error) {
+	if err := m.record(ctx, OP_HEAD_OBJECT, in); err != nil {
+		return nil, err
+	}
+	obj := m.object(*in.Bucket, *in.Key)
+	if obj == nil {
+		// HeadObject のエラーはボディがないので code はステータスから決まる
+		return nil, awsError("NotFound", 404)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := &s3.HeadObjectOutput{
+		ContentLength: aws.Int64(obj.size),
+		ETag:          aws.String(obj.etag),
+		LastModified:  aws.Time(obj.lastModified),
+		Metadata:      obj.metadata,
+	}
+	if obj.storageClass != "" {
+		out.StorageClass = aws.String(obj.storageClass)
+	}
+	if obj.contentType != "" {
+		out.ContentType = aws.String(obj.contentType)
+	}
+	if obj.cacheControl != "" {
+		out.CacheControl = aws.String(obj.cacheControl)
+	}
+	if obj.contentDisposition != "" {
+		out.ContentDisposition = aws.String(obj.contentDisposition)
+	}
+	if obj.contentEncoding != "" {
+		out.ContentEncoding = aws.String(obj.contentEncoding)
+	}
+	if obj.contentLanguage != "" {
+		out.ContentLanguage = aws.String(obj.contentLanguage)
+	}
+	if obj.websiteRedirectLocation != "" {
+		out.WebsiteRedirectLocation = aws.String(obj.websiteRedirectLocation)
+	}
+	return out, nil
+}
+
+func (m *mockS3) CopyObjectWithContext(ctx aws.Context, in *s3.CopyObjectInput, _ ...request.Option) (*s3.CopyObjectOutput, error) {
+	if err := m.record(ctx, OP_COPY_OBJECT, in); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	src, ok := m.objects[mockPath(parseCopySource(*in.CopySource))]
+	if !ok {
+		return nil, awsError("NoSuchKey", 404)
+	}
+	if in.CopySourceIfModifiedSince != nil && !src.lastModified.After(*in.CopySourceIfModifiedSince) {
+		return nil, awsError("PreconditionFailed", 412)
+	}
+	copied := *src
+	copied.lastModified = time.Now()
+	copied.storageClass = aws.StringValue(in.StorageClass)
+	copied.grants = nil
+	if aws.StringValue(in.MetadataDirective) == s3.MetadataDirectiveReplace {
+		copied.metadata = in.Metadata
+		copied.contentType = aws.StringValue(in.ContentType)
+		copied.cacheControl = aws.StringValue(in.CacheControl)
+		copied.contentDisposition = aws.StringValue(in.ContentDisposition)
+		copied.contentEncoding = aws.StringValue(in.ContentEncoding)
+		copied.contentLanguage = aws.StringValue(in.ContentLanguage)
+		copied.websiteRedirectLocation = aws.StringValue(in.WebsiteRedirectLocation)
+	}
+	if aws.StringValue(in.TaggingDirective) == s3.TaggingDirectiveReplace {
+		copied.tags, _ = url.ParseQuery(aws.StringValue(in.Tagging))
+	}
+	m.objects[mockPath(*in.Bucket, *in.Key)] = &copied
+	return &s3.CopyObjectOutput{CopyObjectResult: &s3.CopyObjectResult{ETag: aws.String(copied.etag)}}, nil
+}
+
+func (m *mockS3) CreateMultipartUploadWithContext(ctx aws.Context, in *s3.CreateMultipartUploadInput, _ ...request.Option) (*s3.CreateMultipartUploadOutput, error) {
+	if err := m.record(ctx, OP_CREATE_MULTIPART_UPLOAD, in); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextUploadId++
+	uploadId := fmt.Sprintf("upload-%d", m.nextUploadId)
+	m.uploads[uploadId] = &mockUpload{
+		bucket:    *in.Bucket,
+		key:       *in.Key,
+		initiated: time.Now(),
+		input:     in,
+		parts:     map[int64]*s3.Part{},
+	}
+	return &s3.CreateMultipartUploadOutput{Bucket: in.Bucket, Key: in.Key, UploadId: aws.String(uploadId)}, nil
+}
+
+func (m *mockS3) UploadPartCopyWithContext(ctx aws.Context, in *s3.UploadPartCopyInput, _ ...request.Option) (*s3.UploadPartCopyOutput, error) {
+	if err := m.record(ctx, OP_UPLOAD_PART_COPY, in); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	upload, ok := m.uploads[*in.UploadId]
+	if !ok {
+		return nil, awsError("NoSuchUpload", 404)
+	}
+	src, ok := m.objects[mockPath(parseCopySource(*in.CopySource))]
+	if !ok {
+		return nil, awsError("NoSuchKey", 404)
+	}
+	if in.CopySourceIfModifiedSince != nil && !src.lastModified.After(*in.CopySourceIfModifiedSince) {
+		return nil, awsError("PreconditionFailed", 412)
+	}
+	var first, last int64
+	if _, err := fmt.Sscanf(*in.CopySourceRange, "bytes=%d-%d", &first, &last); err != nil || last >= src.size {
+		return nil, awsError("InvalidRange", 416)
+	}
+	etag := mockETag(fmt.Sprintf("%s/%d", *in.CopySource, *in.PartNumber))
+	upload.parts[*in.PartNumber] = &s3.Part{
+		PartNumber: in.PartNumber,
+		ETag:       aws.String(etag),
+		Size:       aws.Int64(last - first + 1),
+	}
+	return &s3.UploadPartCopyOutput{CopyPartResult: &s3.CopyPartResult{ETag: aws.String(etag)}}, nil
+}
+
+func (m *mockS3) CompleteMultipartUploadWithContext(ctx aws.Context, in *s3.CompleteMultipartUploadInput, _ ...request.Option) (*s3.CompleteMultipartUploadOutput, error) {
+	if err := m.record(ctx, OP_COMPLETE_MULTIPART_UPLOAD, in); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	upload, ok := m.uploads[*in.UploadId]
+	if !ok {
+		return nil, awsError("NoSuchUpload", 404)
+	}
+	var size int64
+	for i, part := range in.MultipartUpload.Parts {
+		uploaded, ok := upload.parts[*part.PartNumber]
+		if *part.PartNumber != int64(i+1) || !ok || strings.Trim(*uploaded.ETag, "\"") != strings.Trim(*part.ETag, "\"") {
+			return nil, awsError("InvalidPart", 400)
+		}
+		size += *uploaded.Size
+	}
+	create := upload.input
+	obj := &mockObject{
+		size:                    size,
+		etag:                    fmt.Sprintf("\"%x-%d\"", md5.Sum([]byte(*in.UploadId)), len(in.MultipartUpload.Parts)),
+		lastModified:            time.Now(),
+		storageClass:            aws.StringValue(create.StorageClass),
+		contentType:             aws.StringValue(create.ContentType),
+		metadata:                create.Metadata,
+		cacheControl:            aws.StringValue(create.CacheControl),
+		contentDisposition:      aws.StringValue(create.ContentDisposition),
+		contentEncoding:         aws.StringValue(create.ContentEncoding),
+		contentLanguage:         aws.StringValue(create.ContentLanguage),
+		websiteRedirectLocation: aws.StringValue(create.WebsiteRedirectLocation),
+	}
+	obj.tags, _ = url.ParseQuery(aws.StringValue(create.Tagging))
+	m.objects[mockPath(upload.bucket, upload.key)] = obj
+	delete(m.uploads, *in.UploadId)
+	return &s3.CompleteMultipartUploadOutput{
+		Bucket:   in.Bucket,
+		Key:      in.Key,
+		ETag:     aws.String(obj.etag),
+		Location: aws.String("https://" + mockPath(upload.bucket, upload.key)),
+	}, nil
+}
+
+func (m *mockS3) AbortMultipartUploadWithContext(ctx aws.Context, in *s3.AbortMultipartUploadInput, _ ...request.Option) (*s3.AbortMultipartUploadOutput, error) {
+	if err := m.record(ctx, OP_ABORT_MULTIPART_UPLOAD, in); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.uploads[*in.UploadId]; !ok {
+		return nil, awsError("NoSuchUpload", 404)
+	}
+	delete(m.uploads, *in.UploadId)
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+func (m *mockS3) ListObjectsV2PagesWithContext(ctx aws.Context, in *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool, _ ...request.Option) error {
+	prefix, delimiter := aws.StringValue(in.Prefix), aws.StringValue(in.Delimiter)
+	m.mu.Lock()
+	var keys []string
+	commonPrefixes := map[string]bool{}
+	for p := range m.objects {
+		bucketKey := strings.SplitN(p, "/", 2)
+		if bucketKey[0] != *in.Bucket || !strings.HasPrefix(bucketKey[1], prefix) {
+			continue
+		}
+		rest := bucketKey[1][len(prefix):]
+		if delimiter != "" && strings.Contains(rest, delimiter) {
+			commonPrefixes[prefix+rest[:strings.Index(rest, delimiter)+len(delimiter)]] = true
+			continue
+		}
+		keys = append(keys, bucketKey[1])
+	}
+	sort.Strings(keys)
+	var contents []*s3.Object
+	for _, k := range keys {
+		obj := m.objects[mockPath(*in.Bucket, k)]
+		contents = append(contents, &s3.Object{
+			Key:          aws.String(k),
+			Size:         aws.Int64(obj.size),
+			ETag:         aws.String(obj.etag),
+			LastModified: aws.Time(obj.lastModified),
+		})
+	}
+	m.mu.Unlock()
+
+	pageSize := m.pageSize
+	if pageSize == 0 {
+		pageSize = 1000
+	}
+	var prefixes []*s3.CommonPrefix
+	for p := range commonPrefixes {
+		prefixes = append(prefixes, &s3.CommonPrefix{Prefix: aws.String(p)})
+	}
+	for start := 0; start == 0 || start < len(contents); start += pageSize {
+		// ページごとに1リクエストとして記録する
+		if err := m.record(ctx, OP_LIST_OBJECTS_V2, in); err != nil {
+			return err
+		}
+		end := start + pageSize
+		if end > len(contents) {
+			end = len(contents)
+		}
+		page := &s3.ListObjectsV2Output{Contents: contents[start:end]}
+		if start == 0 {
+			page.CommonPrefixes = prefixes
+		}
+		if !fn(page, end == len(contents)) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (m *mockS3) GetObjectWithContext(ctx aws.Context, in *s3.GetObjectInput, _ ...request.Option) (*s3.GetObjectOutput, error) {
+	if err := m.record(ctx, OP_GET_OBJECT, in); err != nil {
+		return nil, err
+	}
+	obj := m.object(*in.Bucket, *in.Key)
+	if obj == nil {
+		return nil, awsError("NoSuchKey", 404)
+	}
+	size := obj.size
+	if in.Range != nil {
+		var first, last int64
+		fmt.Sscanf(*in.Range, "bytes=%d-%d", &first, &last)
+		size = last - first + 1
+	}
+	return &s3.GetObjectOutput{
+		ContentLength: aws.Int64(size),
+		Body:          ioutil.NopCloser(bytes.NewReader(make([]byte, size))),
+	}, nil
+}
+
+func (m *mockS3) GetObjectTaggingWithContext(ctx aws.Context, in *s3.GetObjectTaggingInput, _ ...request.Option) (*s3.GetObjectTaggingOutput, error) {
+	if err := m.record(ctx, OP_GET_OBJECT_TAGGING, in); err != nil {
+		return nil, err
+	}
+	obj := m.object(*in.Bucket, *in.Key)
+	if obj == nil {
+		return nil, awsError("NoSuchKey", 404)
+	}
+	out := &s3.GetObjectTaggingOutput{TagSet: []*s3.Tag{}}
+	for k, values := range obj.tags {
+		for _, v := range values {
+			out.TagSet = append(out.TagSet, &s3.Tag{Key: aws.String(k), Value: aws.String(v)})
+		}
+	}
+	return out, nil
+}
+
+func (m *mockS3) GetObjectAclWithContext(ctx aws.Context, in *s3.GetObjectAclInput, _ ...request.Option) (*s3.GetObjectAclOutput, error) {
+	if err := m.record(ctx, OP_GET_OBJECT_ACL, in); err != nil {
+		return nil, err
+	}
+	obj := m.object(*in.Bucket, *in.Key)
+	if obj == nil {
+		return nil, awsError("NoSuchKey", 404)
+	}
+	return &s3.GetObjectAclOutput{Grants: obj.grants}, nil
+}
+
+func (m *mockS3) PutObjectWithContext(ctx aws.Context, in *s3.PutObjectInput, _ ...request.Option) (*s3.PutObjectOutput, error) {
+	if err := m.record(ctx, OP_PUT_OBJECT, in); err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	obj := m.put(*in.Bucket, *in.Key, int64(len(body)))
+	obj.body = body
+	obj.contentType = aws.StringValue(in.ContentType)
+	return &s3.PutObjectOutput{ETag: aws.String(obj.etag)}, nil
+}
+
+func (m *mockS3) ListMultipartUploadsPagesWithContext(ctx aws.Context, in *s3.ListMultipartUploadsInput, fn func(*s3.ListMultipartUploadsOutput, bool) bool, _ ...request.Option) error {
+	m.mu.Lock()
+	var uploads []*s3.MultipartUpload
+	for uploadId, upload := range m.uploads {
+		if upload.bucket == *in.Bucket && strings.HasPrefix(upload.key, aws.StringValue(in.Prefix)) {
+			uploads = append(uploads, &s3.MultipartUpload{
+				Key:       aws.String(upload.key),
+				UploadId:  aws.String(uploadId),
+				Initiated: aws.Time(upload.initiated),
+			})
+		}
+	}
+	m.mu.Unlock()
+	sort.Slice(uploads, func(i, j int) bool {
+		return *uploads[i].UploadId < *uploads[j].UploadId
+	})
+
+	pageSize := m.pageSize
+	if pageSize == 0 {
+		pageSize = 1000
+	}
+	for start := 0; start == 0 || start < len(uploads); start += pageSize {
+		if err := m.record(ctx, OP_LIST_MULTIPART_UPLOADS, in); err != nil {
+			return err
+		}
+		end := start + pageSize
+		if end > len(uploads) {
+			end = len(uploads)
+		}
+		if !fn(&s3.ListMultipartUploadsOutput{Uploads: uploads[start:end]}, end == len(uploads)) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (m *mockS3) ListPartsPagesWithContext(ctx aws.Context, in *s3.ListPartsInput, fn func(*s3.ListPartsOutput, bool) bool, _ ...request.Option) error {
+	if err := m.record(ctx, OP_LIST_PARTS, in); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	upload, ok := m.uploads[*in.UploadId]
+	if !ok {
+		m.mu.Unlock()
+		return awsError("NoSuchUpload", 404)
+	}
+	out := &s3.ListPartsOutput{}
+	for _, part := range upload.parts {
+		out.Parts = append(out.Parts, part)
+	}
+	m.mu.Unlock()
+	sort.Slice(out.Parts, func(i, j int) bool {
+		return *out.Parts[i].PartNumber < *out.Parts[j].PartNumber
+	})
+	fn(out, true)
+	return nil
+}
+
+func (m *mockS3) DeleteObjectWithContext(ctx aws.Context, in *s3.DeleteObjectInput, _ ...request.Option) (*s3.DeleteObjectOutput, error) {
+	if err := m.record(ctx, OP_DELETE_OBJECT, in); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, mockPath(*in.Bucket, *in.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func (m *mockS3) DeleteObjectsWithContext(ctx aws.Context, in *s3.DeleteObjectsInput, _ ...request.Option) (*s3.DeleteObjectsOutput, error) {
+	if err := m.record(ctx, OP_DELETE_OBJECTS, in); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := &s3.DeleteObjectsOutput{}
+	for _, obj := range in.Delete.Objects {
+		if m.failDeletes[*obj.Key] {
+			out.Errors = append(out.Errors, &s3.Error{Key: obj.Key, Code: aws.String("AccessDenied"), Message: aws.String("Access Denied")})
+			continue
+		}
+		delete(m.objects, mockPath(*in.Bucket, *obj.Key))
+	}
+	return out, nil
+}
+
+// putKeys creates objects named prefix0, prefix1, ... of size bytes and returns the keys.
+func (m *mockS3) putKeys(bucket, prefix string, n int, size int64) []string {
+	keys := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		k := prefix + strconv.Itoa(i)
+		m.put(bucket, k, size)
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func TestCopyToSinglePart(t *testing.T) {
+	mock := newMockS3()
+	src := mock.put("src", "a/b.txt", 1024)
+	src.contentType = "text/plain"
+	c := NewS3CopierWithClient(mock)
+
+	err := c.CopyTo(NewS3Object("src", "a/b.txt"), NewS3Object("dest", "a/c.txt"))
+	if err != nil {
+		t.Fatalf("CopyTo failed: %v", err)
+	}
+	dest := mock.object("dest", "a/c.txt")
+	if dest == nil {
+		t.Fatal("destination object is not created")
+	}
+	if dest.size != 1024 || dest.etag != src.etag || dest.contentType != "text/plain" {
+		t.Errorf("destination is %d bytes, ETag %s, ContentType %s; want 1024 bytes, ETag %s, ContentType text/plain", dest.size, dest.etag, dest.contentType, src.etag)
+	}
+	input := mock.inputs(OP_COPY_OBJECT)[0].(*s3.CopyObjectInput)
+	if *input.CopySource != "src/a/b.txt" || *input.Bucket != "dest" || *input.Key != "a/c.txt" {
+		t.Errorf("CopyObject is sent with CopySource %s to %s/%s", *input.CopySource, *input.Bucket, *input.Key)
+	}
+}
+
+func TestCopyToSinglePartBoundaries(t *testing.T) {
+	for _, size := range []int64{0, FIVE_MB} {
+		t.Run(strconv.FormatInt(size, 10), func(t *testing.T) {
+			mock := newMockS3()
+			mock.put("src", "key", size)
+			c := NewS3CopierWithClient(mock)
+
+			if err := c.CopyTo(NewS3Object("src", "key"), NewS3Object("dest", "key")); err != nil {
+				t.Fatalf("CopyTo failed: %v", err)
+			}
+			if n := mock.count(OP_COPY_OBJECT); n != 1 {
+				t.Errorf("CopyObject is called %d times, want 1", n)
+			}
+			if n := mock.count(OP_CREATE_MULTIPART_UPLOAD); n != 0 {
+				t.Errorf("CreateMultipartUpload is called %d times, want 0", n)
+			}
+			if dest := mock.object("dest", "key"); dest == nil || dest.size != size {
+				t.Errorf("destination is %+v, want %d bytes", dest, size)
+			}
+		})
+	}
+}
+
+func TestCopyToReturnsUploadPartCopyError(t *testing.T) {
+	mock := newMockS3()
+	mock.put("src", "key", 50*ONE_MB)
+	mock.hook = func(ctx aws.Context, op string, input interface{}) error {
+		if in, ok := input.(*s3.UploadPartCopyInput); ok && *in.PartNumber == 3 {
+			return awsError("AccessDenied", 403)
+		}
+		return nil
+	}
+	c := NewS3CopierWithClient(mock)
+
+	err := c.CopyTo(NewS3Object("src", "key"), NewS3Object("dest", "key"))
+	if err == nil {
+		t.Fatal("CopyTo succeeded, want the UploadPartCopy error")
+	}
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) || aerr.Code() != "AccessDenied" {
+		t.Errorf("CopyTo failed with %v, want the AccessDenied of UploadPartCopy", err)
+	}
+	if n := mock.count(OP_COMPLETE_MULTIPART_UPLOAD); n != 0 {
+		t.Errorf("CompleteMultipartUpload is called %d times, want 0", n)
+	}
+	if n := mock.count(OP_ABORT_MULTIPART_UPLOAD); n != 1 {
+		t.Errorf("AbortMultipartUpload is called %d times, want 1", n)
+	}
+}
+
+func TestCopyToAbortsOnceOnFailure(t *testing.T) {
+	for _, failing := range []string{OP_UPLOAD_PART_COPY, OP_COMPLETE_MULTIPART_UPLOAD} {
+		t.Run(failing, func(t *testing.T) {
+			mock := newMockS3()
+			mock.put("src", "key", 50*ONE_MB)
+			var parts int32
+			mock.hook = func(ctx aws.Context, op string, input interface{}) error {
+				if op != failing {
+					return nil
+				}
+				// part は途中の1つだけ失敗させる
+				if op == OP_UPLOAD_PART_COPY && atomic.AddInt32(&parts, 1) != 2 {
+					return nil
+				}
+				return awsError("InvalidRequest", 400)
+			}
+			c := NewS3CopierWithClient(mock)
+
+			if err := c.CopyTo(NewS3Object("src", "key"), NewS3Object("dest", "key")); err == nil {
+				t.Fatalf("CopyTo succeeded, want the %s error", failing)
+			}
+			aborts := mock.inputs(OP_ABORT_MULTIPART_UPLOAD)
+			if len(aborts) != 1 {
+				t.Fatalf("AbortMultipartUpload is called %d times, want 1", len(aborts))
+			}
+			abort := aborts[0].(*s3.AbortMultipartUploadInput)
+			if *abort.Bucket != "dest" || *abort.Key != "key" || *abort.UploadId != "upload-1" {
+				t.Errorf("AbortMultipartUpload of %s/%s upload %s, want dest/key upload-1", *abort.Bucket, *abort.Key, *abort.UploadId)
+			}
+			if len(mock.uploads) != 0 || len(c.uploads.snapshot()) != 0 {
+				t.Errorf("%d uploads are left, %d tracked", len(mock.uploads), len(c.uploads.snapshot()))
+			}
+		})
+	}
+}
+
+func TestCopyWithPrefixReturnsListError(t *testing.T) {
+	mock := newMockS3()
+	mock.pageSize = 10
+	mock.putKeys("src", "p/", 30, 1)
+	listErr := awsError("AccessDenied", 403)
+	var pages int32
+	mock.hook = func(ctx aws.Context, op string, input interface{}) error {
+		if op == OP_LIST_OBJECTS_V2 && atomic.AddInt32(&pages, 1) > 1 {
+			return listErr
+		}
+		return nil
+	}
+	c := NewS3CopierWithClient(mock)
+
+	if err := c.CopyWithPrefix("src", "dest", "p/"); err != listErr {
+		t.Errorf("CopyWithPrefix returned %v, want the error of the second page", err)
+	}
+	if n := mock.count(OP_COPY_OBJECT); n > 10 {
+		t.Errorf("%d objects are copied, want at most the 10 of the first page", n)
+	}
+}
+
+func TestCopyToMultiPartCopiesPartsConcurrently(t *testing.T) {
+	mock := newMockS3()
+	mock.put("src", "key", 50*ONE_MB)
+	var inFlight, maxInFlight int32
+	mock.hook = func(ctx aws.Context, op string, input interface{}) error {
+		if op != OP_UPLOAD_PART_COPY {
+			return nil
+		}
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		// 他の part と重なるように少し待つ
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	}
+	c := NewS3CopierWithClient(mock, WithPartSize(10*ONE_MB))
+
+	if err := c.CopyTo(NewS3Object("src", "key"), NewS3Object("dest", "key")); err != nil {
+		t.Fatalf("CopyTo failed: %v", err)
+	}
+	ranges := map[int64]string{}
+	for _, in := range mock.inputs(OP_UPLOAD_PART_COPY) {
+		part := in.(*s3.UploadPartCopyInput)
+		ranges[*part.PartNumber] = *part.CopySourceRange
+	}
+	if len(ranges) != 5 {
+		t.Fatalf("parts %v are copied, want 5 parts", ranges)
+	}
+	for partNum := int64(1); partNum <= 5; partNum++ {
+		want := fmt.Sprintf("bytes=%d-%d", (partNum-1)*10*ONE_MB, partNum*10*ONE_MB-1)
+		if ranges[partNum] != want {
+			t.Errorf("part %d has range %s, want %s", partNum, ranges[partNum], want)
+		}
+	}
+	if maxInFlight < 2 {
+		t.Errorf("at most %d part is copied at a time, want concurrent part copies", maxInFlight)
+	}
+	complete := mock.inputs(OP_COMPLETE_MULTIPART_UPLOAD)[0].(*s3.CompleteMultipartUploadInput)
+	for i, part := range complete.MultipartUpload.Parts {
+		if *part.PartNumber != int64(i+1) {
+			t.Errorf("completed part %d has PartNumber %d, want sorted part numbers", i, *part.PartNumber)
+		}
+	}
+	if dest := mock.object("dest", "key"); dest == nil || dest.size != 50*ONE_MB {
+		t.Errorf("destination is %+v, want %d bytes", dest, 50*ONE_MB)
+	}
+}
+
+// checkGoroutines fails t unless the number of goroutines goes back to before,
+// i.e. the goroutines started since then have exited.
+func checkGoroutines(t *testing.T, before int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<20)
+			t.Fatalf("%d goroutines are left, want %d:\n%s", runtime.NumGoroutine(), before, buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCopyWithPrefixLeavesNoGoroutine(t *testing.T) {
+	mock := newMockS3()
+	mock.putKeys("src", "p/", 100, 1)
+	mock.put("src", "p/large", 30*ONE_MB)
+	c := NewS3CopierWithClient(mock)
+	before := runtime.NumGoroutine()
+
+	result, err := c.CopyWithPrefixResult("src", "dest", "p/")
+	if err != nil {
+		t.Fatalf("CopyWithPrefixResult failed: %v", err)
+	}
+	if result.ObjectsCopied != 101 {
+		t.Errorf("%d objects are copied, want 101", result.ObjectsCopied)
+	}
+	checkGoroutines(t, before)
+}
+
+func TestCopyWithPrefixStopsWorkersOnError(t *testing.T) {
+	mock := newMockS3()
+	mock.putKeys("src", "p/", 1000, 1)
+	mock.hook = func(ctx aws.Context, op string, input interface{}) error {
+		if in, ok := input.(*s3.CopyObjectInput); ok && *in.Key == "p/0" {
+			return awsError("AccessDenied", 403)
+		}
+		return nil
+	}
+	c := NewS3CopierWithClient(mock, WithWorkerCount(10), WithChannelBuffer(10))
+	before := runtime.NumGoroutine()
+
+	err := c.CopyWithPrefix("src", "dest", "p/")
+	if !errors.Is(err, ErrAccessDenied) {
+		t.Fatalf("CopyWithPrefix returned %v, want the error of p/0", err)
+	}
+	checkGoroutines(t, before)
+	// 残りの key は取られずに終わる
+	if n := mock.count(OP_COPY_OBJECT); n >= 1000 {
+		t.Errorf("%d objects are copied after the error, want the run to stop", n)
+	}
+}
+
+func TestCopyToStaysWithinPartLimit(t *testing.T) {
+	const size = 100 * 1024 * ONE_MB
+	mock := newMockS3()
+	mock.put("src", "key", size)
+	c := NewS3CopierWithClient(mock, WithPartSize(10*ONE_MB))
+
+	if partSize := c.partSizeFor(size); (size+partSize-1)/partSize > MAX_PART_COUNT {
+		t.Errorf("part size %d splits %d bytes into more than %d parts", partSize, int64(size), MAX_PART_COUNT)
+	}
+	if err := c.CopyTo(NewS3Object("src", "key"), NewS3Object("dest", "key")); err != nil {
+		t.Fatalf("CopyTo failed: %v", err)
+	}
+	complete := mock.inputs(OP_COMPLETE_MULTIPART_UPLOAD)[0].(*s3.CompleteMultipartUploadInput)
+	if n := len(complete.MultipartUpload.Parts); n > MAX_PART_COUNT {
+		t.Errorf("%d parts are completed, want at most %d", n, MAX_PART_COUNT)
+	}
+	if dest := mock.object("dest", "key"); dest == nil || dest.size != size {
+		t.Errorf("destination is %+v, want %d bytes", dest, int64(size))
+	}
+}
+
+func TestCrossRegionUsesSourceAndDestinationClients(t *testing.T) {
+	srcClient, destClient := newMockS3(), newMockS3()
+	for _, m := range []*mockS3{srcClient, destClient} {
+		// コピーは destination 側で source を読むので両方に置く
+		m.put("src", "p/single", 1)
+		m.put("src", "p/multi", 20*ONE_MB)
+	}
+	c := NewS3CopierCrossRegion(srcClient, destClient)
+
+	if err := c.CopyWithPrefix("src", "dest", "p/"); err != nil {
+		t.Fatalf("CopyWithPrefix failed: %v", err)
+	}
+	reads := []string{OP_LIST_OBJECTS_V2, OP_HEAD_OBJECT, OP_GET_OBJECT_TAGGING}
+	writes := []string{OP_COPY_OBJECT, OP_CREATE_MULTIPART_UPLOAD, OP_UPLOAD_PART_COPY, OP_COMPLETE_MULTIPART_UPLOAD}
+	for _, op := range reads {
+		if srcClient.count(op) == 0 || destClient.count(op) != 0 {
+			t.Errorf("%s is sent %d times to the source client and %d times to the destination client", op, srcClient.count(op), destClient.count(op))
+		}
+	}
+	for _, op := range writes {
+		if srcClient.count(op) != 0 || destClient.count(op) == 0 {
+			t.Errorf("%s is sent %d times to the source client and %d times to the destination client", op, srcClient.count(op), destClient.count(op))
+		}
+	}
+	for _, key := range []string{"p/single", "p/multi"} {
+		if destClient.object("dest", key) == nil {
+			t.Errorf("dest/%s is not created by the destination client", key)
+		}
+	}
+}
+
+func TestCopyKeysCopiesOnlyTheGivenKeys(t *testing.T) {
+	mock := newMockS3()
+	mock.putKeys("src", "p/", 5, 1)
+	c := NewS3CopierWithClient(mock)
+
+	if err := c.CopyKeys("src", "dest", []string{"p/0", "p/2", "p/4"}); err != nil {
+		t.Fatalf("CopyKeys failed: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		key := "p/" + strconv.Itoa(i)
+		if copied, want := mock.object("dest", key) != nil, i%2 == 0; copied != want {
+			t.Errorf("dest/%s is copied: %v, want %v", key, copied, want)
+		}
+	}
+	if n := mock.count(OP_COPY_OBJECT); n != 3 {
+		t.Errorf("CopyObject is called %d times, want 3", n)
+	}
+	if n := mock.count(OP_LIST_OBJECTS_V2); n != 0 {
+		t.Errorf("ListObjectsV2 is called %d times, want no listing", n)
+	}
+}
+
+func TestWithDelimiterCopiesOnlyDirectChildren(t *testing.T) {
+	mock := newMockS3()
+	for _, key := range []string{"p/a", "p/b", "p/sub/c"} {
+		mock.put("src", key, 1)
+	}
+	c := NewS3CopierWithClient(mock, WithDelimiter("/"))
+
+	result, err := c.CopyWithPrefixResult("src", "dest", "p/")
+	if err != nil {
+		t.Fatalf("CopyWithPrefixResult failed: %v", err)
+	}
+	if result.ObjectsCopied != 2 || mock.object("dest", "p/a") == nil || mock.object("dest", "p/b") == nil {
+		t.Errorf("%d objects are copied, want p/a and p/b", result.ObjectsCopied)
+	}
+	if mock.object("dest", "p/sub/c") != nil {
+		t.Error("p/sub/c under the delimiter is copied")
+	}
+	input := mock.inputs(OP_LIST_OBJECTS_V2)[0].(*s3.ListObjectsV2Input)
+	if aws.StringValue(input.Delimiter) != "/" {
+		t.Errorf("ListObjectsV2 is sent with Delimiter %q, want /", aws.StringValue(input.Delimiter))
+	}
+}
+
+func TestCopyWithPrefixWaitsForEveryPage(t *testing.T) {
+	mock := newMockS3()
+	mock.pageSize = 10
+	mock.putKeys("src", "p/", 1000, 1)
+	mock.hook = func(ctx aws.Context, op string, input interface{}) error {
+		if op == OP_LIST_OBJECTS_V2 {
+			// 次のページが来る前にそれまでの key のコピーが全部終わるようにする
+			time.Sleep(time.Millisecond)
+		}
+		return nil
+	}
+	c := NewS3CopierWithClient(mock, WithWorkerCount(20))
+
+	result, err := c.CopyWithPrefixResult("src", "dest", "p/")
+	if err != nil {
+		t.Fatalf("CopyWithPrefixResult failed: %v", err)
+	}
+	if result.ObjectsCopied != 1000 || mock.count(OP_COPY_OBJECT) != 1000 {
+		t.Errorf("%d objects are copied with %d CopyObject, want 1000", result.ObjectsCopied, mock.count(OP_COPY_OBJECT))
+	}
+	if n := mock.count(OP_LIST_OBJECTS_V2); n != 100 {
+		t.Errorf("%d pages are listed, want 100", n)
+	}
+}
+
+func TestDryRunWritesNothing(t *testing.T) {
+	mock := newMockS3()
+	mock.putKeys("src", "p/", 3, 100)
+	mock.put("src", "p/large", 20*ONE_MB)
+	c := NewS3CopierWithClient(mock, WithDryRun(true), WithFailureReportKey("reports", "report.json"))
+
+	result, err := c.CopyWithPrefixResult("src", "dest", "p/")
+	if err != nil {
+		t.Fatalf("CopyWithPrefixResult failed: %v", err)
+	}
+	if result.ObjectsCopied != 4 || result.BytesCopied != 300+20*ONE_MB || result.SinglePartCount != 3 || result.MultiPartCount != 1 {
+		t.Errorf("result is %+v, want 4 objects of %d bytes, 3 in a single part and 1 in multipart", result, 300+20*ONE_MB)
+	}
+	for _, op := range []string{OP_COPY_OBJECT, OP_CREATE_MULTIPART_UPLOAD, OP_UPLOAD_PART_COPY, OP_COMPLETE_MULTIPART_UPLOAD, OP_PUT_OBJECT} {
+		if n := mock.count(op); n != 0 {
+			t.Errorf("%s is sent %d times in a dry run", op, n)
+		}
+	}
+
+	if err := c.MoveWithPrefix("src", "dest", "p/"); err != nil {
+		t.Fatalf("MoveWithPrefix failed: %v", err)
+	}
+	if n := mock.count(OP_DELETE_OBJECTS); n != 0 {
+		t.Errorf("DeleteObjects is sent %d times in a dry run", n)
+	}
+}
+
+// partETagS3 rewrites the ETags UploadPartCopy returns.
+type partETagS3 struct {
+	*mockS3
+	etag func(etag string) *string
+}
+
+func (m *partETagS3) UploadPartCopyWithContext(ctx aws.Context, in *s3.UploadPartCopyInput, opts ...request.Option) (*s3.UploadPartCopyOutput, error) {
+	out, err := m.mockS3.UploadPartCopyWithContext(ctx, in, opts...)
+	if err == nil {
+		out.CopyPartResult.ETag = m.etag(*out.CopyPartResult.ETag)
+	}
+	return out, err
+}
+
+func TestCopyToPartETags(t *testing.T) {
+	tests := []struct {
+		name string
+		etag func(etag string) *string
+		err  bool
+	}{
+		{name: "quoted", etag: aws.String},
+		{name: "unquoted", etag: func(etag string) *string { return aws.String(strings.Trim(etag, "\"")) }},
+		{name: "empty", etag: func(string) *string { return aws.String("") }, err: true},
+		{name: "quotes only", etag: func(string) *string { return aws.String("\"\"") }, err: true},
+		{name: "nil", etag: func(string) *string { return nil }, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &partETagS3{mockS3: newMockS3(), etag: tt.etag}
+			mock.put("src", "key", 20*ONE_MB)
+			c := NewS3CopierWithClient(mock, WithMaxRetries(0))
+
+			err := c.CopyTo(NewS3Object("src", "key"), NewS3Object("dest", "key"))
+			if !tt.err {
+				if err != nil {
+					t.Fatalf("CopyTo failed: %v", err)
+				}
+				for _, part := range mock.inputs(OP_COMPLETE_MULTIPART_UPLOAD)[0].(*s3.CompleteMultipartUploadInput).MultipartUpload.Parts {
+					if strings.Contains(*part.ETag, "\"") {
+						t.Errorf("part %d is completed with ETag %s, want it unquoted", *part.PartNumber, *part.ETag)
+					}
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), "no ETag") {
+				t.Errorf("CopyTo returned %v, want an error for the missing ETag", err)
+			}
+			if n := mock.count(OP_COMPLETE_MULTIPART_UPLOAD); n != 0 {
+				t.Errorf("CompleteMultipartUpload is called %d times, want none", n)
+			}
+			if n := mock.count(OP_ABORT_MULTIPART_UPLOAD); n != 1 {
+				t.Errorf("AbortMultipartUpload is called %d times, want 1", n)
+			}
+		})
+	}
+}
+
+func TestCopyWithPrefixManyKeysWithSmallBuffers(t *testing.T) {
+	const n = 50000
+	mock := newMockS3()
+	mock.putKeys("src", "p/", n, 1)
+	c := NewS3CopierWithClient(mock, WithChannelBuffer(1), WithWorkerCount(2))
+
+	result, err := c.CopyWithPrefixResult("src", "dest", "p/")
+	if err != nil {
+		t.Fatalf("CopyWithPrefixResult failed: %v", err)
+	}
+	if result.ObjectsCopied != n || mock.count(OP_COPY_OBJECT) != n {
+		t.Errorf("%d objects are copied with %d CopyObject, want %d", result.ObjectsCopied, mock.count(OP_COPY_OBJECT), n)
+	}
+}
+
+func TestCopyToVersion(t *testing.T) {
+	for _, size := range []int64{1, 20 * ONE_MB} {
+		t.Run(strconv.FormatInt(size, 10), func(t *testing.T) {
+			mock := newMockS3()
+			mock.put("src", "key", size)
+			c := NewS3CopierWithClient(mock)
+
+			if err := c.CopyTo(NewS3ObjectVersion("src", "key", "v1+/="), NewS3Object("dest", "key")); err != nil {
+				t.Fatalf("CopyTo failed: %v", err)
+			}
+			head := mock.inputs(OP_HEAD_OBJECT)[0].(*s3.HeadObjectInput)
+			if aws.StringValue(head.VersionId) != "v1+/=" {
+				t.Errorf("HeadObject is sent with VersionId %q, want v1+/=", aws.StringValue(head.VersionId))
+			}
+			var copySources []string
+			for _, input := range mock.inputs(OP_COPY_OBJECT) {
+				copySources = append(copySources, *input.(*s3.CopyObjectInput).CopySource)
+			}
+			for _, input := range mock.inputs(OP_UPLOAD_PART_COPY) {
+				copySources = append(copySources, *input.(*s3.UploadPartCopyInput).CopySource)
+			}
+			if len(copySources) == 0 {
+				t.Fatal("nothing is copied")
+			}
+			for _, copySource := range copySources {
+				if copySource != "src/key?versionId=v1%2B%2F%3D" {
+					t.Errorf("copied with CopySource %s, want src/key?versionId=v1%%2B%%2F%%3D", copySource)
+				}
+			}
+		})
+	}
+}
+
+func TestCopySourceEncoding(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{key: "folder/a b+c?.txt", want: "src/folder/a%20b%2Bc%3F.txt"},
+		{key: "日本語/ファイル.mp4", want: "src/%E6%97%A5%E6%9C%AC%E8%AA%9E/%E3%83%95%E3%82%A1%E3%82%A4%E3%83%AB.mp4"},
+		{key: "a//b/", want: "src/a//b/"},
+	}
+	for _, tt := range tests {
+		if got := NewS3Object("src", tt.key).copySource(); got != tt.want {
+			t.Errorf("copySource of %q = %s, want %s", tt.key, got, tt.want)
+		}
+	}
+
+	mock := newMockS3()
+	for _, tt := range tests {
+		mock.put("src", tt.key, 20*ONE_MB)
+	}
+	c := NewS3CopierWithClient(mock)
+	for _, tt := range tests {
+		if err := c.CopyTo(NewS3Object("src", tt.key), NewS3Object("dest", tt.key)); err != nil {
+			t.Errorf("CopyTo %q failed: %v", tt.key, err)
+		}
+	}
+}
+
+func TestCopyToHeadsTheSourceOnce(t *testing.T) {
+	mock := newMockS3()
+	mock.put("src", "key", 30*ONE_MB)
+	c := NewS3CopierWithClient(mock)
+
+	if err := c.CopyTo(NewS3Object("src", "key"), NewS3Object("dest", "key")); err != nil {
+		t.Fatalf("CopyTo failed: %v", err)
+	}
+	if n := mock.count(OP_HEAD_OBJECT); n != 1 {
+		t.Errorf("HeadObject is called %d times, want 1", n)
+	}
+}
+
+// emptyCompleteS3 answers the first empty CompleteMultipartUpload requests with 200 and no ETag,
+// as S3 does when the error is in the body.
+type emptyCompleteS3 struct {
+	*mockS3
+	empty int32
+}
+
+func (m *emptyCompleteS3) CompleteMultipartUploadWithContext(ctx aws.Context, in *s3.CompleteMultipartUploadInput, opts ...request.Option) (*s3.CompleteMultipartUploadOutput, error) {
+	if atomic.AddInt32(&m.empty, -1) >= 0 {
+		if err := m.record(ctx, OP_COMPLETE_MULTIPART_UPLOAD, in); err != nil {
+			return nil, err
+		}
+		return &s3.CompleteMultipartUploadOutput{}, nil
+	}
+	return m.mockS3.CompleteMultipartUploadWithContext(ctx, in, opts...)
+}
+
+func TestCopyToEmptyCompleteResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		empty   int32
+		retries int
+		err     bool
+	}{
+		{name: "failed", empty: 1, retries: 0, err: true},
+		{name: "retried", empty: 1, retries: 1},
+		{name: "failed after retry", empty: 2, retries: 1, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &emptyCompleteS3{mockS3: newMockS3(), empty: tt.empty}
+			mock.put("src", "key", 20*ONE_MB)
+			c := NewS3CopierWithClient(mock, WithMaxRetries(tt.retries))
+			fakeSleep(c.retryer)
+
+			err := c.CopyTo(NewS3Object("src", "key"), NewS3Object("dest", "key"))
+			if n := mock.count(OP_COMPLETE_MULTIPART_UPLOAD); n != tt.retries+1 {
+				t.Errorf("CompleteMultipartUpload is called %d times, want %d", n, tt.retries+1)
+			}
+			if !tt.err {
+				if err != nil {
+					t.Fatalf("CopyTo failed: %v", err)
+				}
+				if mock.object("dest", "key") == nil {
+					t.Error("destination object is not created")
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), "no ETag") {
+				t.Errorf("CopyTo returned %v, want an error for the empty response", err)
+			}
+			if n := mock.count(OP_ABORT_MULTIPART_UPLOAD); n != 1 {
+				t.Errorf("AbortMultipartUpload is called %d times, want 1", n)
+			}
+		})
+	}
+}
+
+func TestConcurrentRunsAreIndependent(t *testing.T) {
+	mock := newMockS3()
+	mock.putKeys("src", "a/", 300, 1)
+	mock.putKeys("src", "b/", 700, 2)
+	// progress は run をまたいでも並行に呼ばれない
+	progress := map[string]int{}
+	c := NewS3CopierWithClient(mock, WithProgress(func(ev ProgressEvent) {
+		progress[ev.Key[:2]]++
+	}))
+
+	prefixes := []string{"a/", "b/"}
+	results := make([]*CopyResult, len(prefixes))
+	errs := make([]error, len(prefixes))
+	var wg sync.WaitGroup
+	for i, prefix := range prefixes {
+		wg.Add(1)
+		go func(i int, prefix string) {
+			defer wg.Done()
+			results[i], errs[i] = c.CopyWithPrefixResult("src", "dest", prefix)
+		}(i, prefix)
+	}
+	wg.Wait()
+
+	for i, want := range []struct {
+		objects int
+		bytes   int64
+	}{{300, 300}, {700, 1400}} {
+		if errs[i] != nil {
+			t.Fatalf("CopyWithPrefixResult %s failed: %v", prefixes[i], errs[i])
+		}
+		if results[i].ObjectsCopied != want.objects || results[i].BytesCopied != want.bytes {
+			t.Errorf("run of %s copied %d objects of %d bytes, want %d of %d", prefixes[i], results[i].ObjectsCopied, results[i].BytesCopied, want.objects, want.bytes)
+		}
+		if n := progress[prefixes[i]]; n != want.objects {
+			t.Errorf("progress is reported %d times for %s, want %d", n, prefixes[i], want.objects)
+		}
+	}
+	if n := mock.count(OP_COPY_OBJECT); n != 1000 {
+		t.Errorf("CopyObject is called %d times, want 1000", n)
+	}
+}
+
+func TestCancelDuringMultipartCopy(t *testing.T) {
+	for _, withPrefix := range []bool{false, true} {
+		mock := newMockS3()
+		mock.put("src", "p/key", 50*ONE_MB)
+		started := make(chan struct{}, 1)
+		mock.hook = func(ctx aws.Context, op string, input interface{}) error {
+			if op == OP_UPLOAD_PART_COPY {
+				started <- struct{}{}
+				<-ctx.Done()
+				return ctx.Err()
+			}
+			return nil
+		}
+		// part を1つずつコピーして、キャンセルの後に次の part が送られないことを見る
+		c := NewS3CopierWithClient(mock, WithPartConcurrency(1))
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() {
+			if withPrefix {
+				done <- c.CopyWithPrefixContext(ctx, "src", "dest", "p/")
+			} else {
+				done <- c.CopyToContext(ctx, NewS3Object("src", "p/key"), NewS3Object("dest", "p/key"))
+			}
+		}()
+
+		<-started
+		cancel()
+		err := <-done
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("with prefix %v, returned %v, want context.Canceled", withPrefix, err)
+		}
+		if n := mock.count(OP_UPLOAD_PART_COPY); n != 1 {
+			t.Errorf("with prefix %v, UploadPartCopy is called %d times, want no part after the cancel", withPrefix, n)
+		}
+		create := mock.inputs(OP_CREATE_MULTIPART_UPLOAD)
+		aborts := mock.inputs(OP_ABORT_MULTIPART_UPLOAD)
+		if len(create) != 1 || len(aborts) != 1 || *aborts[0].(*s3.AbortMultipartUploadInput).Key != "p/key" {
+			t.Errorf("with prefix %v, %d uploads are created and %d aborted, want the upload to be aborted", withPrefix, len(create), len(aborts))
+		}
+		if mock.object("dest", "p/key") != nil || mock.count(OP_COMPLETE_MULTIPART_UPLOAD) != 0 {
+			t.Errorf("with prefix %v, the cancelled upload is completed", withPrefix)
+		}
+	}
+}
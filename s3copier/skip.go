@@ -0,0 +1,32 @@
+package s3copier
+
+import "github.com/aws/aws-sdk-go/aws/awserr"
+
+// WithSkippableSourceErrors makes CopyWithPrefix skip an object and continue, instead of failing
+// the run, when HeadObject on the source fails with one of the AWS error codes.
+// HeadObject responses have no body, so the codes are the ones the SDK derives from the status,
+// e.g. "Forbidden" and "NotFound" rather than "AccessDenied" and "NoSuchKey".
+// The skipped keys are reported with the error as the reason.
+func WithSkippableSourceErrors(codes ...string) Option {
+	return func(c *S3Copier) {
+		if c.skippableSourceErrors == nil {
+			c.skippableSourceErrors = map[string]bool{}
+		}
+		for _, code := range codes {
+			c.skippableSourceErrors[code] = true
+		}
+	}
+}
+
+// WithSkipMissing makes CopyWithPrefix skip the objects which are deleted from the source
+// after being listed, instead of failing the run. The keys are reported in CopyResult.Missing.
+func WithSkipMissing(skip bool) Option {
+	return func(c *S3Copier) {
+		c.skipMissing = skip
+	}
+}
+
+func (c *S3Copier) isSkippableSourceError(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && c.skippableSourceErrors[aerr.Code()]
+}
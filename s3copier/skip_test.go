@@ -0,0 +1,105 @@
+package s3copier
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func TestSkippableSourceErrorsAreRecorded(t *testing.T) {
+	mock := newMockS3()
+	mock.putKeys("src", "p/", 3, 1)
+	mock.hook = func(ctx aws.Context, op string, input interface{}) error {
+		if in, ok := input.(*s3.HeadObjectInput); ok && *in.Key == "p/1" {
+			return awsError("Forbidden", 403)
+		}
+		return nil
+	}
+	c := NewS3CopierWithClient(mock, WithSkippableSourceErrors("Forbidden"))
+
+	result, err := c.CopyWithPrefixResult("src", "dest", "p/")
+	if err != nil {
+		t.Fatalf("CopyWithPrefixResult failed: %v", err)
+	}
+	if result.ObjectsCopied != 2 || result.Skipped != 1 {
+		t.Errorf("%d objects copied and %d skipped, want 2 and 1", result.ObjectsCopied, result.Skipped)
+	}
+	if len(result.SkippedObjects) != 1 {
+		t.Fatalf("SkippedObjects = %+v, want p/1", result.SkippedObjects)
+	}
+	skipped := result.SkippedObjects[0]
+	if skipped.Key != "p/1" || !strings.Contains(skipped.Reason, "Forbidden") {
+		t.Errorf("SkippedObjects[0] = %+v, want p/1 with the Forbidden error", skipped)
+	}
+	if mock.object("dest", "p/1") != nil {
+		t.Error("the skipped object is copied")
+	}
+}
+
+func TestSkippedObjectsHaveReasons(t *testing.T) {
+	mock := newMockS3()
+	mock.putKeys("src", "p/", 2, 1)
+	c := NewS3CopierWithClient(mock, WithHeadPredicate(func(head *s3.HeadObjectOutput) bool {
+		return false
+	}))
+
+	result, err := c.CopyWithPrefixResult("src", "dest", "p/")
+	if err != nil {
+		t.Fatalf("CopyWithPrefixResult failed: %v", err)
+	}
+	got := result.SkippedObjects
+	// 完了順なので key でそろえる
+	if len(got) == 2 && got[0].Key > got[1].Key {
+		got[0], got[1] = got[1], got[0]
+	}
+	want := []SkippedObject{
+		{Key: "p/0", Reason: SKIP_REASON_HEAD_PREDICATE},
+		{Key: "p/1", Reason: SKIP_REASON_HEAD_PREDICATE},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SkippedObjects = %+v, want %+v", got, want)
+	}
+}
+
+// deleteOnHead deletes bucket/key from the source just before it is headed, as if it was deleted after the listing.
+func deleteOnHead(mock *mockS3, bucket, key string) {
+	mock.hook = func(ctx aws.Context, op string, input interface{}) error {
+		if in, ok := input.(*s3.HeadObjectInput); ok && *in.Bucket == bucket && *in.Key == key {
+			mock.mu.Lock()
+			delete(mock.objects, mockPath(bucket, key))
+			mock.mu.Unlock()
+		}
+		return nil
+	}
+}
+
+func TestWithSkipMissing(t *testing.T) {
+	mock := newMockS3()
+	mock.putKeys("src", "p/", 3, 1)
+	deleteOnHead(mock, "src", "p/1")
+	c := NewS3CopierWithClient(mock, WithSkipMissing(true))
+
+	result, err := c.CopyWithPrefixResult("src", "dest", "p/")
+	if err != nil {
+		t.Fatalf("CopyWithPrefixResult failed: %v", err)
+	}
+	if result.ObjectsCopied != 2 || result.Skipped != 1 || !reflect.DeepEqual(result.Missing, []string{"p/1"}) {
+		t.Errorf("%d objects copied, %d skipped and %v missing, want 2, 1 and [p/1]", result.ObjectsCopied, result.Skipped, result.Missing)
+	}
+}
+
+func TestMissingSourceFailsWithoutSkipMissing(t *testing.T) {
+	mock := newMockS3()
+	mock.putKeys("src", "p/", 3, 1)
+	deleteOnHead(mock, "src", "p/1")
+	c := NewS3CopierWithClient(mock)
+
+	err := c.CopyWithPrefix("src", "dest", "p/")
+	if !errors.Is(err, ErrSourceNotFound) || !strings.Contains(err.Error(), "src/p/1") {
+		t.Errorf("CopyWithPrefix returned %v, want ErrSourceNotFound for src/p/1", err)
+	}
+}
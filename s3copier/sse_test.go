@@ -0,0 +1,75 @@
+package s3copier
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func TestServerSideEncryption(t *testing.T) {
+	tests := []struct {
+		name      string
+		opt       Option
+		algorithm string
+		kmsKeyID  *string
+	}{
+		{name: "SSE-KMS", opt: WithSSEKMS("arn:aws:kms:ap-northeast-1:123456789012:key/abc"), algorithm: s3.ServerSideEncryptionAwsKms, kmsKeyID: aws.String("arn:aws:kms:ap-northeast-1:123456789012:key/abc")},
+		{name: "SSE-KMS with the AWS managed key", opt: WithSSEKMS(""), algorithm: s3.ServerSideEncryptionAwsKms},
+		{name: "SSE-S3", opt: WithSSES3(), algorithm: s3.ServerSideEncryptionAes256},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := newMockS3()
+			mock.put("src", "p/single", 1)
+			mock.put("src", "p/multi", 20*ONE_MB)
+			c := NewS3CopierWithClient(mock, tt.opt)
+
+			if err := c.CopyWithPrefix("src", "dest", "p/"); err != nil {
+				t.Fatalf("CopyWithPrefix failed: %v", err)
+			}
+			single := mock.inputs(OP_COPY_OBJECT)[0].(*s3.CopyObjectInput)
+			if aws.StringValue(single.ServerSideEncryption) != tt.algorithm || aws.StringValue(single.SSEKMSKeyId) != aws.StringValue(tt.kmsKeyID) {
+				t.Errorf("CopyObject has ServerSideEncryption %v and SSEKMSKeyId %v", single.ServerSideEncryption, single.SSEKMSKeyId)
+			}
+			multi := mock.inputs(OP_CREATE_MULTIPART_UPLOAD)[0].(*s3.CreateMultipartUploadInput)
+			if aws.StringValue(multi.ServerSideEncryption) != tt.algorithm || aws.StringValue(multi.SSEKMSKeyId) != aws.StringValue(tt.kmsKeyID) {
+				t.Errorf("CreateMultipartUpload has ServerSideEncryption %v and SSEKMSKeyId %v", multi.ServerSideEncryption, multi.SSEKMSKeyId)
+			}
+		})
+	}
+}
+
+func TestSSECustomerKeyRotation(t *testing.T) {
+	mock := newMockS3()
+	mock.put("src", "p/single", 1)
+	mock.put("src", "p/multi", 20*ONE_MB)
+	c := NewS3CopierWithClient(mock, WithSourceSSECustomerKey("AES256", "old-key"), WithSSECustomerKey("AES256", "new-key"))
+
+	if err := c.CopyWithPrefix("src", "dest", "p/"); err != nil {
+		t.Fatalf("CopyWithPrefix failed: %v", err)
+	}
+	checkKeys := func(op string, sourceKey, destKey *string) {
+		t.Helper()
+		if aws.StringValue(sourceKey) != "old-key" || aws.StringValue(destKey) != "new-key" {
+			t.Errorf("%s is sent with CopySourceSSECustomerKey %q and SSECustomerKey %q, want old-key and new-key", op, aws.StringValue(sourceKey), aws.StringValue(destKey))
+		}
+	}
+	single := mock.inputs(OP_COPY_OBJECT)[0].(*s3.CopyObjectInput)
+	checkKeys(OP_COPY_OBJECT, single.CopySourceSSECustomerKey, single.SSECustomerKey)
+	for _, input := range mock.inputs(OP_UPLOAD_PART_COPY) {
+		in := input.(*s3.UploadPartCopyInput)
+		checkKeys(OP_UPLOAD_PART_COPY, in.CopySourceSSECustomerKey, in.SSECustomerKey)
+	}
+	create := mock.inputs(OP_CREATE_MULTIPART_UPLOAD)[0].(*s3.CreateMultipartUploadInput)
+	if aws.StringValue(create.SSECustomerKey) != "new-key" || aws.StringValue(create.SSECustomerAlgorithm) != "AES256" {
+		t.Errorf("CreateMultipartUpload is sent with SSECustomerKey %q, want new-key", aws.StringValue(create.SSECustomerKey))
+	}
+	// source は古い鍵で head する
+	for _, input := range mock.inputs(OP_HEAD_OBJECT) {
+		in := input.(*s3.HeadObjectInput)
+		if *in.Bucket == "src" && aws.StringValue(in.SSECustomerKey) != "old-key" {
+			t.Errorf("HeadObject of src/%s is sent with SSECustomerKey %q, want old-key", *in.Key, aws.StringValue(in.SSECustomerKey))
+		}
+	}
+}
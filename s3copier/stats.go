@@ -0,0 +1,64 @@
+package s3copier
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats is a snapshot of the running CopyWithPrefix, to see whether listing or copying is the bottleneck.
+// If QueuedJobs stays at the channel capacity copying is the bottleneck, if it stays at 0 listing is.
+type Stats struct {
+	QueuedJobs int
+	Enqueued   int64
+	Dequeued   int64
+	// jobs per second since the run started
+	EnqueueRate float64
+	DequeueRate float64
+}
+
+type runStats struct {
+	enqueued int64
+	dequeued int64
+
+	// run の開始時に決まり、以降は変わらない
+	jobs      chan string
+	startedAt time.Time
+}
+
+// startStats returns the statistics of a new run, which Stats reports from then on.
+func (c *S3Copier) startStats(jobs chan string) *runStats {
+	s := &runStats{jobs: jobs, startedAt: time.Now()}
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	c.stats = s
+	return s
+}
+
+func (s *runStats) enqueue() {
+	atomic.AddInt64(&s.enqueued, 1)
+}
+
+func (s *runStats) dequeue() {
+	atomic.AddInt64(&s.dequeued, 1)
+}
+
+// Stats returns the statistics of the current (or last) CopyWithPrefix.
+// When several runs are in progress, it is the one started last.
+func (c *S3Copier) Stats() Stats {
+	c.statsMu.Lock()
+	s := c.stats
+	c.statsMu.Unlock()
+	if s == nil {
+		return Stats{}
+	}
+	stats := Stats{
+		QueuedJobs: len(s.jobs),
+		Enqueued:   atomic.LoadInt64(&s.enqueued),
+		Dequeued:   atomic.LoadInt64(&s.dequeued),
+	}
+	if elapsed := time.Since(s.startedAt).Seconds(); !s.startedAt.IsZero() && elapsed > 0 {
+		stats.EnqueueRate = float64(stats.Enqueued) / elapsed
+		stats.DequeueRate = float64(stats.Dequeued) / elapsed
+	}
+	return stats
+}
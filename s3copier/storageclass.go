@@ -0,0 +1,23 @@
+package s3copier
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// WithStorageClass sets the storage class of every destination object, e.g. s3.StorageClassStandardIa.
+// By default the storage class of each source object is kept.
+func WithStorageClass(storageClass string) Option {
+	return func(c *S3Copier) {
+		c.storageClass = storageClass
+	}
+}
+
+// storageClassFor returns nil for STANDARD objects, since HeadObject omits the header for them.
+func (c *S3Copier) storageClassFor(srcHead *s3.HeadObjectOutput) *string {
+	if c.storageClass != "" {
+		return aws.String(c.storageClass)
+	}
+	// 指定しないと STANDARD になってしまうので source のものを引き継ぐ
+	return srcHead.StorageClass
+}
@@ -0,0 +1,54 @@
+package s3copier
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func TestStorageClass(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{name: "preserved", want: s3.StorageClassStandardIa},
+		{name: "overridden", opts: []Option{WithStorageClass(s3.StorageClassGlacier)}, want: s3.StorageClassGlacier},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := newMockS3()
+			mock.put("src", "p/single", 1).storageClass = s3.StorageClassStandardIa
+			mock.put("src", "p/multi", 20*ONE_MB).storageClass = s3.StorageClassStandardIa
+			c := NewS3CopierWithClient(mock, tt.opts...)
+
+			if err := c.CopyWithPrefix("src", "dest", "p/"); err != nil {
+				t.Fatalf("CopyWithPrefix failed: %v", err)
+			}
+			if got := *mock.inputs(OP_COPY_OBJECT)[0].(*s3.CopyObjectInput).StorageClass; got != tt.want {
+				t.Errorf("CopyObject StorageClass = %s, want %s", got, tt.want)
+			}
+			if got := *mock.inputs(OP_CREATE_MULTIPART_UPLOAD)[0].(*s3.CreateMultipartUploadInput).StorageClass; got != tt.want {
+				t.Errorf("CreateMultipartUpload StorageClass = %s, want %s", got, tt.want)
+			}
+			for _, key := range []string{"p/single", "p/multi"} {
+				if got := mock.object("dest", key).storageClass; got != tt.want {
+					t.Errorf("dest/%s has storage class %s, want %s", key, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestStorageClassOfStandardObjectsIsNotSet(t *testing.T) {
+	mock := newMockS3()
+	mock.put("src", "key", 1)
+	c := NewS3CopierWithClient(mock)
+
+	if err := c.CopyTo(NewS3Object("src", "key"), NewS3Object("dest", "key")); err != nil {
+		t.Fatalf("CopyTo failed: %v", err)
+	}
+	if sc := mock.inputs(OP_COPY_OBJECT)[0].(*s3.CopyObjectInput).StorageClass; sc != nil {
+		t.Errorf("CopyObject StorageClass = %s, want nil for STANDARD", *sc)
+	}
+}
@@ -0,0 +1,51 @@
+package s3copier
+
+import (
+	"context"
+	"sync"
+)
+
+// CopyOutcome is the result of a key copied by CopyStream.
+type CopyOutcome struct {
+	Key string
+	Err error
+	// Bytes is the size of the object copied, 0 when it failed or was skipped
+	Bytes int64
+	// Skipped is true when the object was not copied, e.g. by WithSkipExisting
+	Skipped bool
+}
+
+// CopyStream copies the keys received from keys with the workers of the copier and sends
+// an outcome per key. A failed key doesn't stop the others. The returned channel is closed
+// when keys is closed and drained, or ctx is done, and all the copies in progress have finished.
+// The caller must receive all the outcomes, otherwise the workers block.
+// The listing options (filters, WithMaxObjects etc.) don't apply, since nothing is listed.
+func (c *S3Copier) CopyStream(ctx context.Context, srcBucket, destBucket string, keys <-chan string) <-chan CopyOutcome {
+	outcomes := make(chan CopyOutcome, c.workerCount)
+	var workers sync.WaitGroup
+	for w := 0; w < c.workerCount; w++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for {
+				key, ok := nextKey(ctx, keys)
+				if !ok {
+					return
+				}
+				result := c.copyKey(ctx, srcBucket, destBucket, key)
+				c.observeResult(result)
+				outcome := CopyOutcome{Key: key, Err: result.err, Skipped: result.skipReason != ""}
+				if result.err == nil && result.skipReason == "" {
+					outcome.Bytes = result.size
+				}
+				// キャンセルされても処理した key の結果は返す
+				outcomes <- outcome
+			}
+		}()
+	}
+	go func() {
+		workers.Wait()
+		close(outcomes)
+	}()
+	return outcomes
+}
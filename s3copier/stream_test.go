@@ -0,0 +1,62 @@
+package s3copier
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+)
+
+func TestCopyStream(t *testing.T) {
+	mock := newMockS3()
+	mock.put("src", "small", 100)
+	mock.put("src", "large", 20*ONE_MB)
+	mock.put("src", "existing", 10)
+	mock.put("dest", "existing", 10).etag = mock.object("src", "existing").etag
+	c := NewS3CopierWithClient(mock, WithSkipExisting(true))
+	before := runtime.NumGoroutine()
+
+	keys := make(chan string)
+	go func() {
+		for _, key := range []string{"small", "large", "existing", "missing"} {
+			keys <- key
+		}
+		close(keys)
+	}()
+	outcomes := map[string]CopyOutcome{}
+	for outcome := range c.CopyStream(context.Background(), "src", "dest", keys) {
+		outcomes[outcome.Key] = outcome
+	}
+
+	if o := outcomes["small"]; o.Err != nil || o.Skipped || o.Bytes != 100 {
+		t.Errorf("small is %+v, want 100 bytes copied", o)
+	}
+	if o := outcomes["large"]; o.Err != nil || o.Bytes != 20*ONE_MB || mock.object("dest", "large") == nil {
+		t.Errorf("large is %+v, want %d bytes copied", o, 20*ONE_MB)
+	}
+	if o := outcomes["existing"]; o.Err != nil || !o.Skipped || o.Bytes != 0 {
+		t.Errorf("existing is %+v, want it skipped", o)
+	}
+	if o := outcomes["missing"]; !errors.Is(o.Err, ErrSourceNotFound) || o.Bytes != 0 {
+		t.Errorf("missing is %+v, want ErrSourceNotFound", o)
+	}
+	if len(outcomes) != 4 {
+		t.Errorf("%d outcomes are sent, want 4", len(outcomes))
+	}
+	checkGoroutines(t, before)
+}
+
+func TestCopyStreamStopsOnCancel(t *testing.T) {
+	mock := newMockS3()
+	c := NewS3CopierWithClient(mock)
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// keys は閉じられないままキャンセルされる
+	outcomes := c.CopyStream(ctx, "src", "dest", make(chan string))
+	cancel()
+	for outcome := range outcomes {
+		t.Errorf("outcome %+v is sent without keys", outcome)
+	}
+	checkGoroutines(t, before)
+}
@@ -0,0 +1,47 @@
+package s3copier
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// WithPreserveTags controls whether the object tags of the source are set on the destination.
+// It is true by default. CopyObject keeps the tags by itself, while for multipart copies
+// the tags are read with GetObjectTagging and set when the upload is created.
+// With false, the destination objects have no tags.
+func WithPreserveTags(preserve bool) Option {
+	return func(c *S3Copier) {
+		c.preserveTags = preserve
+	}
+}
+
+// sourceTagging returns the tags of src in the query string form of the Tagging header,
+// or nil when src has no tags.
+func (c *S3Copier) sourceTagging(ctx context.Context, src *S3Object) (*string, error) {
+	input := &s3.GetObjectTaggingInput{
+		Bucket:    aws.String(src.bucket),
+		Key:       aws.String(src.key),
+		VersionId: src.versionIdValue(),
+	}
+	c.decorate(OP_GET_OBJECT_TAGGING, input)
+	var output *s3.GetObjectTaggingOutput
+	err := c.retryer.do(ctx, func() error {
+		var err error
+		output, err = c.srcClient.GetObjectTaggingWithContext(ctx, input)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(output.TagSet) == 0 {
+		return nil, nil
+	}
+	values := url.Values{}
+	for _, tag := range output.TagSet {
+		values.Add(aws.StringValue(tag.Key), aws.StringValue(tag.Value))
+	}
+	return aws.String(values.Encode()), nil
+}
@@ -0,0 +1,46 @@
+package s3copier
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func TestPreserveTags(t *testing.T) {
+	tags := url.Values{"project": {"migration"}, "owner": {"media team"}}
+	tests := []struct {
+		name     string
+		preserve bool
+		want     url.Values
+	}{
+		{name: "preserved", preserve: true, want: tags},
+		{name: "dropped", preserve: false, want: url.Values{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := newMockS3()
+			mock.put("src", "p/single", 1).tags = tags
+			mock.put("src", "p/multi", 20*ONE_MB).tags = tags
+			c := NewS3CopierWithClient(mock, WithPreserveTags(tt.preserve))
+
+			if err := c.CopyWithPrefix("src", "dest", "p/"); err != nil {
+				t.Fatalf("CopyWithPrefix failed: %v", err)
+			}
+			for _, key := range []string{"p/single", "p/multi"} {
+				got := mock.object("dest", key).tags
+				if got == nil {
+					got = url.Values{}
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("dest/%s has tags %v, want %v", key, got, tt.want)
+				}
+			}
+			directive := mock.inputs(OP_COPY_OBJECT)[0].(*s3.CopyObjectInput).TaggingDirective
+			if replaced := directive != nil && *directive == s3.TaggingDirectiveReplace; replaced == tt.preserve {
+				t.Errorf("CopyObject TaggingDirective = %v with preserve %v", directive, tt.preserve)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package s3copier
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// 成功を挟まずにこの回数 SlowDown が返ってきたら part size を倍にする
+const THROTTLE_THRESHOLD = 5
+
+// WithAdaptivePartSizeForThrottle doubles the part size used for subsequent multipart copies
+// every time UploadPartCopy has been throttled (SlowDown) a few times in a row, up to maxPartSize.
+// Fewer, larger part copies are issued while S3 is throttling, which a static part size can't do.
+func WithAdaptivePartSizeForThrottle(maxPartSize int64) Option {
+	return func(c *S3Copier) {
+		if maxPartSize > MAX_PART_SIZE {
+			maxPartSize = MAX_PART_SIZE
+		}
+		c.throttle = &throttleAdapter{maxPartSize: maxPartSize}
+	}
+}
+
+type throttleAdapter struct {
+	maxPartSize int64
+
+	mu        sync.Mutex
+	partSize  int64
+	throttled int
+}
+
+// observe records a throttled part copy made with the given part size,
+// and returns the new part size when it has been increased.
+func (t *throttleAdapter) observe(partSize int64) (int64, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.partSize < partSize {
+		t.partSize = partSize
+	}
+	t.throttled++
+	if t.throttled < THROTTLE_THRESHOLD || t.partSize >= t.maxPartSize {
+		return 0, false
+	}
+	t.throttled = 0
+	t.partSize *= 2
+	if t.partSize > t.maxPartSize {
+		t.partSize = t.maxPartSize
+	}
+	return t.partSize, true
+}
+
+// succeeded records a part copy which was not throttled. Throttling only counts while it is sustained,
+// so a few SlowDowns scattered over a long run don't increase the part size.
+func (t *throttleAdapter) succeeded() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.throttled = 0
+}
+
+func (t *throttleAdapter) currentPartSize() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.partSize
+}
+
+// isThrottled reports whether err is S3 throttling the requests. The SDK doesn't know SlowDown,
+// the code S3 returns with 503, as a throttle error.
+func isThrottled(err error) bool {
+	return errorCode(err) == "SlowDown" || statusCode(err) == http.StatusServiceUnavailable || request.IsErrorThrottle(err)
+}
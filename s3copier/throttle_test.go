@@ -0,0 +1,70 @@
+package s3copier
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestThrottleIncreasesPartSizeOnSlowDown(t *testing.T) {
+	mock := newMockS3()
+	mock.put("src", "key", 20*ONE_MB)
+	var calls int32
+	mock.hook = func(ctx aws.Context, op string, input interface{}) error {
+		if op == OP_UPLOAD_PART_COPY && atomic.AddInt32(&calls, 1) <= THROTTLE_THRESHOLD {
+			return awsError("SlowDown", 503)
+		}
+		return nil
+	}
+	c := NewS3CopierWithClient(mock, WithAdaptivePartSizeForThrottle(100*ONE_MB), WithMaxRetries(THROTTLE_THRESHOLD))
+	// part は並行にコピーされるので記録せずに待たないだけにする
+	c.retryer.sleep = func(ctx context.Context, d time.Duration) error { return nil }
+
+	if err := c.CopyTo(NewS3Object("src", "key"), NewS3Object("dest", "key")); err != nil {
+		t.Fatalf("CopyTo failed: %v", err)
+	}
+	if got := c.throttle.currentPartSize(); got != 20*ONE_MB {
+		t.Errorf("part size is %d bytes after %d SlowDowns, want %d", got, THROTTLE_THRESHOLD, 20*ONE_MB)
+	}
+}
+
+func TestThrottleNeedsSustainedThrottling(t *testing.T) {
+	throttle := &throttleAdapter{maxPartSize: 100 * ONE_MB}
+	for i := 0; i < 3; i++ {
+		for j := 0; j < THROTTLE_THRESHOLD-1; j++ {
+			if _, increased := throttle.observe(10 * ONE_MB); increased {
+				t.Fatal("part size is increased by scattered throttling")
+			}
+		}
+		throttle.succeeded()
+	}
+	for j := 0; j < THROTTLE_THRESHOLD-1; j++ {
+		throttle.observe(10 * ONE_MB)
+	}
+	partSize, increased := throttle.observe(10 * ONE_MB)
+	if !increased || partSize != 20*ONE_MB {
+		t.Errorf("observe returned %d, %v after %d throttles in a row, want %d, true", partSize, increased, THROTTLE_THRESHOLD, 20*ONE_MB)
+	}
+}
+
+func TestIsThrottled(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{awsError("SlowDown", 503), true},
+		{awsError("ServiceUnavailable", 503), true},
+		{awsError("Throttling", 400), true},
+		{awsError("InternalError", 500), false},
+		{awsError("AccessDenied", 403), false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := isThrottled(tt.err); got != tt.want {
+			t.Errorf("isThrottled(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package s3copier
+
+import "context"
+
+// Span names passed to the Tracer.
+const (
+	SPAN_COPY_OBJECT               = "s3copier.CopyObject"
+	SPAN_UPLOAD_PART_COPY          = "s3copier.UploadPartCopy"
+	SPAN_COMPLETE_MULTIPART_UPLOAD = "s3copier.CompleteMultipartUpload"
+)
+
+// Tracer starts the spans of the copier. It is a small subset of what tracing libraries provide,
+// so that e.g. an OpenTelemetry adapter is a few lines without the copier depending on it.
+type Tracer interface {
+	// Start returns ctx with the new span, which is passed to the requests and child spans
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a span started by Tracer.
+type Span interface {
+	SetAttribute(key string, value interface{})
+	// End finishes the span, err is nil on success
+	End(err error)
+}
+
+// WithTracer sets the Tracer of the copier, which traces each object copy, and for multipart copies
+// each UploadPartCopy and the CompleteMultipartUpload. Nothing is traced by default.
+func WithTracer(tracer Tracer) Option {
+	return func(c *S3Copier) {
+		c.tracer = tracer
+	}
+}
+
+type nopTracer struct{}
+
+func (nopTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	return ctx, nopSpan{}
+}
+
+type nopSpan struct{}
+
+func (nopSpan) SetAttribute(key string, value interface{}) {}
+func (nopSpan) End(err error)                              {}
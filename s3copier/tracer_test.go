@@ -0,0 +1,101 @@
+package s3copier
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+type fakeSpan struct {
+	name       string
+	parent     *fakeSpan
+	attributes map[string]interface{}
+	ended      int
+	err        error
+}
+
+type fakeSpanKey struct{}
+
+// fakeTracer records the spans, with the span of the ctx passed to Start as the parent.
+type fakeTracer struct {
+	mu    sync.Mutex
+	spans []*fakeSpan
+}
+
+func (t *fakeTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	parent, _ := ctx.Value(fakeSpanKey{}).(*fakeSpan)
+	span := &fakeSpan{name: name, parent: parent, attributes: map[string]interface{}{}}
+	t.spans = append(t.spans, span)
+	return context.WithValue(ctx, fakeSpanKey{}, span), &fakeSpanHandle{tracer: t, span: span}
+}
+
+type fakeSpanHandle struct {
+	tracer *fakeTracer
+	span   *fakeSpan
+}
+
+func (h *fakeSpanHandle) SetAttribute(key string, value interface{}) {
+	h.tracer.mu.Lock()
+	defer h.tracer.mu.Unlock()
+	h.span.attributes[key] = value
+}
+
+func (h *fakeSpanHandle) End(err error) {
+	h.tracer.mu.Lock()
+	defer h.tracer.mu.Unlock()
+	h.span.ended++
+	h.span.err = err
+}
+
+func TestWithTracer(t *testing.T) {
+	mock := newMockS3()
+	mock.put("src", "single", 1)
+	mock.put("src", "multi", 25*ONE_MB)
+	tracer := &fakeTracer{}
+	c := NewS3CopierWithClient(mock, WithTracer(tracer))
+
+	for _, key := range []string{"single", "multi"} {
+		if err := c.CopyTo(NewS3Object("src", key), NewS3Object("dest", key)); err != nil {
+			t.Fatalf("CopyTo %s failed: %v", key, err)
+		}
+	}
+	counts := map[string]int{}
+	for _, span := range tracer.spans {
+		counts[span.name]++
+		if span.ended != 1 || span.err != nil {
+			t.Errorf("span %s is ended %d times with %v, want once without error", span.name, span.ended, span.err)
+		}
+		switch span.name {
+		case SPAN_COPY_OBJECT:
+			if span.parent != nil || span.attributes["s3copier.source"] == nil || span.attributes["s3copier.size"] == nil {
+				t.Errorf("span %s has parent %v and attributes %v, want a root span with the source and the size", span.name, span.parent, span.attributes)
+			}
+		case SPAN_UPLOAD_PART_COPY, SPAN_COMPLETE_MULTIPART_UPLOAD:
+			if span.parent == nil || span.parent.name != SPAN_COPY_OBJECT || span.parent.attributes["s3copier.source"] != "src/multi" {
+				t.Errorf("span %s is not a child of the copy of src/multi", span.name)
+			}
+		}
+	}
+	// 25MB は 10MB ずつ 3 part
+	want := map[string]int{SPAN_COPY_OBJECT: 2, SPAN_UPLOAD_PART_COPY: 3, SPAN_COMPLETE_MULTIPART_UPLOAD: 1}
+	for name, n := range want {
+		if counts[name] != n {
+			t.Errorf("%d spans %s are started, want %d", counts[name], name, n)
+		}
+	}
+}
+
+func TestWithTracerEndsSpansOnError(t *testing.T) {
+	mock := newMockS3()
+	tracer := &fakeTracer{}
+	c := NewS3CopierWithClient(mock, WithTracer(tracer))
+
+	if err := c.CopyTo(NewS3Object("src", "missing"), NewS3Object("dest", "missing")); err == nil {
+		t.Fatal("CopyTo of a missing source succeeded")
+	}
+	if len(tracer.spans) != 1 || tracer.spans[0].ended != 1 || tracer.spans[0].err == nil {
+		t.Errorf("spans are %+v, want one span ended with the error", tracer.spans)
+	}
+}
@@ -0,0 +1,81 @@
+package s3copier
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+const (
+	TWO_PHASE_TEMP_SUFFIX = ".s3copier-tmp"
+	// CopyObject 1回でコピーできる上限
+	MAX_COPY_OBJECT_SIZE = 5 * 1024 * 1024 * 1024
+)
+
+// WithTwoPhaseCommit copies the keys for which fn returns true to a temporary key first,
+// then copies the temporary object to the final key with a single CopyObject and deletes it.
+// The temporary object is deleted on failure too. Objects copied this way must be at most 5GB.
+//
+// S3 already makes each single object write atomic: readers of a key never see a half-written
+// object, even while a multipart upload is in progress. This mode only adds value when several
+// objects form one logical unit, so that each final key is only written once its content is complete.
+func WithTwoPhaseCommit(fn func(key string) bool) Option {
+	return func(c *S3Copier) {
+		c.twoPhaseCommit = fn
+	}
+}
+
+func temporaryObject(dest *S3Object) *S3Object {
+	return &S3Object{bucket: dest.bucket, key: dest.key + TWO_PHASE_TEMP_SUFFIX}
+}
+
+// promoteTemporary copies temp to dest as is. temp is already encrypted with the destination key.
+func (c *S3Copier) promoteTemporary(ctx context.Context, src, temp, dest *S3Object, storageClass *string) error {
+	input := &s3.CopyObjectInput{
+		Bucket:       aws.String(dest.bucket),
+		Key:          aws.String(dest.key),
+		CopySource:   aws.String(temp.copySource()),
+		StorageClass: storageClass,
+	}
+	if c.preserveACL {
+		// CopyObject では ACL は引き継がれないので source から読み直す。
+		// temp の ACL には WithGrant* の grant も入っていて、decorator で二重に付いてしまう
+		grants, err := c.sourceGrants(ctx, c.srcClient, src)
+		if err != nil {
+			return fmt.Errorf("%s: failed to promote %s: %v", dest.bucketKeyPath(), temp.key, err)
+		}
+		input.GrantRead, input.GrantReadACP, input.GrantWriteACP, input.GrantFullControl = grants[grantRead], grants[grantReadACP], grants[grantWriteACP], grants[grantFullControl]
+	}
+	c.decorate(OP_COPY_OBJECT, input)
+	input.CopySourceSSECustomerAlgorithm = c.sseCustomerKey.algorithmValue()
+	input.CopySourceSSECustomerKey = c.sseCustomerKey.keyValue()
+	err := c.retryer.do(ctx, func() error {
+		_, err := c.destClient.CopyObjectWithContext(ctx, input)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("%s: failed to promote %s: %v", dest.bucketKeyPath(), temp.key, err)
+	}
+	return nil
+}
+
+// deleteTemporary doesn't take the context of the copy, since it has to run even after the copy is
+// cancelled. It gives up after the drain timeout instead, as abortMultipartUpload does.
+func (c *S3Copier) deleteTemporary(temp *S3Object) {
+	input := &s3.DeleteObjectInput{
+		Bucket: aws.String(temp.bucket),
+		Key:    aws.String(temp.key),
+	}
+	c.decorate(OP_DELETE_OBJECT, input)
+	ctx, cancel := context.WithTimeout(context.Background(), c.drainTimeout)
+	defer cancel()
+	err := c.retryer.do(ctx, func() error {
+		_, err := c.destClient.DeleteObjectWithContext(ctx, input)
+		return err
+	})
+	if err != nil {
+		c.logger.Errorf("failed to delete temporary object %s: %v", temp.bucketKeyPath(), err)
+	}
+}
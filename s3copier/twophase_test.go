@@ -0,0 +1,93 @@
+package s3copier
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func inFinal(key string) bool {
+	return strings.HasPrefix(key, "p/final/")
+}
+
+func TestTwoPhaseCommit(t *testing.T) {
+	for _, size := range []int64{1, 20 * ONE_MB} {
+		mock := newMockS3()
+		mock.put("src", "p/final/a", size)
+		mock.put("src", "p/b", size)
+		c := NewS3CopierWithClient(mock, WithTwoPhaseCommit(inFinal))
+
+		if err := c.CopyWithPrefix("src", "dest", "p/"); err != nil {
+			t.Fatalf("CopyWithPrefix of %d bytes failed: %v", size, err)
+		}
+		for _, key := range []string{"p/final/a", "p/b"} {
+			if dest := mock.object("dest", key); dest == nil || dest.size != size {
+				t.Errorf("dest/%s is %+v, want %d bytes", key, dest, size)
+			}
+		}
+		if mock.object("dest", "p/final/a"+TWO_PHASE_TEMP_SUFFIX) != nil {
+			t.Errorf("temporary object of %d bytes is left", size)
+		}
+		var promoted bool
+		for _, input := range mock.inputs(OP_COPY_OBJECT) {
+			in := input.(*s3.CopyObjectInput)
+			if *in.Key == "p/final/a" {
+				promoted = *in.CopySource == "dest/p/final/a"+TWO_PHASE_TEMP_SUFFIX
+			}
+		}
+		if !promoted {
+			t.Errorf("dest/p/final/a of %d bytes is not copied from the temporary object", size)
+		}
+		deletes := mock.inputs(OP_DELETE_OBJECT)
+		if len(deletes) != 1 || *deletes[0].(*s3.DeleteObjectInput).Key != "p/final/a"+TWO_PHASE_TEMP_SUFFIX {
+			t.Errorf("%d objects are deleted, want only the temporary object", len(deletes))
+		}
+	}
+}
+
+func TestTwoPhaseCommitDeletesTemporaryOnFailure(t *testing.T) {
+	mock := newMockS3()
+	mock.put("src", "p/final/a", 1)
+	mock.hook = func(ctx aws.Context, op string, input interface{}) error {
+		if in, ok := input.(*s3.CopyObjectInput); ok && strings.HasSuffix(*in.CopySource, TWO_PHASE_TEMP_SUFFIX) {
+			return awsError("InternalError", 500)
+		}
+		return nil
+	}
+	c := NewS3CopierWithClient(mock, WithTwoPhaseCommit(inFinal), WithMaxRetries(0))
+
+	err := c.CopyWithPrefix("src", "dest", "p/")
+	if err == nil || !strings.Contains(err.Error(), "failed to promote") {
+		t.Errorf("CopyWithPrefix returned %v, want the promotion to fail", err)
+	}
+	if mock.object("dest", "p/final/a"+TWO_PHASE_TEMP_SUFFIX) != nil {
+		t.Error("temporary object is left after the failure")
+	}
+	if mock.object("dest", "p/final/a") != nil {
+		t.Error("final object is written although the promotion failed")
+	}
+}
+
+func TestTwoPhaseCommitSetsGrantsOnce(t *testing.T) {
+	mock := newMockS3()
+	mock.put("src", "p/final/a", 1).grants = []*s3.Grant{
+		{Grantee: &s3.Grantee{Type: aws.String(s3.TypeCanonicalUser), ID: aws.String("reader")}, Permission: aws.String(s3.PermissionRead)},
+	}
+	c := NewS3CopierWithClient(mock, WithTwoPhaseCommit(inFinal), WithPreserveACL(), WithGrantRead(`id="extra"`))
+
+	if err := c.CopyWithPrefix("src", "dest", "p/"); err != nil {
+		t.Fatalf("CopyWithPrefix failed: %v", err)
+	}
+	copies := mock.inputs(OP_COPY_OBJECT)
+	if len(copies) != 2 {
+		t.Fatalf("CopyObject is called %d times, want 2", len(copies))
+	}
+	for _, input := range copies {
+		in := input.(*s3.CopyObjectInput)
+		if got := aws.StringValue(in.GrantRead); got != `id="reader", id="extra"` {
+			t.Errorf("CopyObject to %s is sent with GrantRead %s, want id=\"reader\", id=\"extra\"", *in.Key, got)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package s3copier
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+const (
+	DEFAULT_VERIFY_READABLE_THRESHOLD = FIVE_MB
+	// 読めることが確認できれば良いので先頭の数バイトだけ取得する
+	VERIFY_READABLE_BYTES = 16
+)
+
+// WithVerify makes the copier head every destination object after copying it and compare
+// the ContentLength with the source, and the ETag too when both are plain MD5s, i.e. for objects
+// copied in a single part without SSE-KMS or SSE-C. A mismatch fails the object.
+func WithVerify(enabled bool) Option {
+	return func(c *S3Copier) {
+		c.verify = enabled
+	}
+}
+
+// WithVerifyRecopy makes WithVerify copy a mismatched object once more before failing it.
+func WithVerifyRecopy(enabled bool) Option {
+	return func(c *S3Copier) {
+		c.verifyRecopy = enabled
+	}
+}
+
+// WithVerifyReadable makes the copier head every destination object after copying it and,
+// for objects smaller than the threshold (see WithVerifyReadableThreshold), also get its first bytes.
+// This catches objects which are written but can't be read, e.g. because of KMS key permissions.
+func WithVerifyReadable(enabled bool) Option {
+	return func(c *S3Copier) {
+		c.verifyReadable = enabled
+	}
+}
+
+// WithVerifyReadableThreshold sets the size below which WithVerifyReadable gets the object.
+func WithVerifyReadableThreshold(size int64) Option {
+	return func(c *S3Copier) {
+		c.verifyReadableThreshold = size
+	}
+}
+
+func (c *S3Copier) verifyCopy(ctx context.Context, srcHead *s3.HeadObjectOutput, dest *S3Object) error {
+	head, err := c.headObject(ctx, c.destClient, dest, c.sseCustomerKey)
+	if err != nil {
+		return fmt.Errorf("verify %s: destination can't be headed: %v", dest.bucketKeyPath(), err)
+	}
+	srcSize, destSize := aws.Int64Value(srcHead.ContentLength), aws.Int64Value(head.ContentLength)
+	if srcSize != destSize {
+		return fmt.Errorf("verify %s: size mismatch: source %d bytes, destination %d bytes", dest.bucketKeyPath(), srcSize, destSize)
+	}
+	if isMD5ETag(srcHead.ETag, srcHead.ServerSideEncryption, srcHead.SSECustomerAlgorithm) &&
+		isMD5ETag(head.ETag, head.ServerSideEncryption, head.SSECustomerAlgorithm) &&
+		aws.StringValue(srcHead.ETag) != aws.StringValue(head.ETag) {
+		return fmt.Errorf("verify %s: ETag mismatch: source %s, destination %s", dest.bucketKeyPath(), aws.StringValue(srcHead.ETag), aws.StringValue(head.ETag))
+	}
+	return nil
+}
+
+// isMD5ETag reports whether etag is the MD5 of the content, which is not the case
+// for multipart uploads and for objects encrypted with SSE-KMS or SSE-C.
+func isMD5ETag(etag, serverSideEncryption, sseCustomerAlgorithm *string) bool {
+	if etag == nil || isMultipartETag(*etag) {
+		return false
+	}
+	return aws.StringValue(serverSideEncryption) != s3.ServerSideEncryptionAwsKms && sseCustomerAlgorithm == nil
+}
+
+func (c *S3Copier) verifyDestinationReadable(ctx context.Context, dest *S3Object) error {
+	head, err := c.headObject(ctx, c.destClient, dest, c.sseCustomerKey)
+	if err != nil {
+		return fmt.Errorf("verify %s: destination can't be headed: %v", dest.bucketKeyPath(), err)
+	}
+	size := aws.Int64Value(head.ContentLength)
+	if size == 0 || size >= c.verifyReadableThreshold {
+		return nil
+	}
+
+	last := int64(VERIFY_READABLE_BYTES)
+	if last > size {
+		last = size
+	}
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(dest.bucket),
+		Key:    aws.String(dest.key),
+		Range:  aws.String(fmt.Sprintf("bytes=0-%d", last-1)),
+	}
+	c.decorate(OP_GET_OBJECT, input)
+	err = c.retryer.do(ctx, func() error {
+		output, err := c.destClient.GetObjectWithContext(ctx, input)
+		if err != nil {
+			return err
+		}
+		defer output.Body.Close()
+		_, err = io.Copy(ioutil.Discard, output.Body)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("verify %s: destination can't be read: %v", dest.bucketKeyPath(), err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package s3copier
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// truncatingS3 reports the destination objects one byte shorter for the first truncated heads,
+// as if they were copied partially.
+type truncatingS3 struct {
+	*mockS3
+	truncated int32
+}
+
+func (m *truncatingS3) HeadObjectWithContext(ctx aws.Context, in *s3.HeadObjectInput, opts ...request.Option) (*s3.HeadObjectOutput, error) {
+	out, err := m.mockS3.HeadObjectWithContext(ctx, in, opts...)
+	if err == nil && *in.Bucket == "dest" && atomic.AddInt32(&m.truncated, -1) >= 0 {
+		out.ContentLength = aws.Int64(*out.ContentLength - 1)
+	}
+	return out, err
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name      string
+		truncated int32
+		recopy    bool
+		copies    int
+		err       string
+	}{
+		{name: "matching", copies: 1},
+		{name: "size mismatch", truncated: 1, copies: 1, err: "size mismatch"},
+		{name: "size mismatch copied again", truncated: 1, recopy: true, copies: 2},
+		{name: "size mismatch after copying again", truncated: 2, recopy: true, copies: 2, err: "size mismatch"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &truncatingS3{mockS3: newMockS3(), truncated: tt.truncated}
+			mock.put("src", "key", 100)
+			c := NewS3CopierWithClient(mock, WithVerify(true), WithVerifyRecopy(tt.recopy))
+
+			err := c.CopyTo(NewS3Object("src", "key"), NewS3Object("dest", "key"))
+			if tt.err == "" && err != nil {
+				t.Errorf("CopyTo failed: %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("CopyTo returned %v, want %q", err, tt.err)
+			}
+			if n := mock.count(OP_COPY_OBJECT); n != tt.copies {
+				t.Errorf("CopyObject is called %d times, want %d", n, tt.copies)
+			}
+		})
+	}
+}
+
+func TestVerifyETagMismatch(t *testing.T) {
+	mock := newMockS3()
+	mock.put("src", "key", 100)
+	// 検証の head の直前に destination が別の内容で上書きされる
+	c := NewS3CopierWithClient(mock, WithVerify(true), WithRequestDecorator(func(op string, input interface{}) {
+		if op == OP_HEAD_OBJECT && *input.(*s3.HeadObjectInput).Bucket == "dest" {
+			mock.object("dest", "key").etag = mockETag("other")
+		}
+	}))
+
+	err := c.CopyTo(NewS3Object("src", "key"), NewS3Object("dest", "key"))
+	if err == nil || !strings.Contains(err.Error(), "ETag mismatch") {
+		t.Errorf("CopyTo returned %v, want an ETag mismatch", err)
+	}
+}